@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// LabelCardinality is a label of the cardinality provider and its number of distinct values.
+type LabelCardinality struct {
+	Name   string `yaml:"name"`
+	Values int    `yaml:"values"`
+}
+
+// CardinalitySpec configures the series of cardinalityDataProvider.
+type CardinalitySpec struct {
+	// Series is the number of active series, spread over Metrics metric names.
+	Series  int `yaml:"series"`
+	Metrics int `yaml:"metrics"`
+	// Labels are added to every series besides the series_id label making it unique, their values
+	// derived from the series id.
+	Labels []LabelCardinality `yaml:"labels"`
+	// ChurnPerMinute is the number of series retired and replaced by new ones per minute.
+	ChurnPerMinute int `yaml:"churn_per_minute"`
+}
+
+// DefaultCardinalitySpec returns the cardinality settings used unless the scenario sets them.
+func DefaultCardinalitySpec() CardinalitySpec {
+	return CardinalitySpec{
+		Series:  10000,
+		Metrics: 10,
+		Labels: []LabelCardinality{
+			{Name: "pod", Values: 100},
+			{Name: "endpoint", Values: 20},
+		},
+	}
+}
+
+// Validate checks the settings.
+func (c CardinalitySpec) Validate() error {
+	if c.Series <= 0 || c.Metrics <= 0 {
+		return errors.New("cardinality series and metrics must be greater than zero")
+	}
+	if c.ChurnPerMinute < 0 {
+		return errors.New("cardinality churn_per_minute must not be negative")
+	}
+	for _, label := range c.Labels {
+		if label.Name == "" || label.Values <= 0 {
+			return fmt.Errorf("cardinality label %q needs a name and values greater than zero", label.Name)
+		}
+	}
+	return nil
+}
+
+// cardinalityDataProvider is a testbed.DataProvider of gauges over a configurable number of active
+// series, the driver of the head memory of Prometheus. Series are numbered, the active ones being the
+// Series ids from an offset growing by ChurnPerMinute, so that series are retired and new ones created
+// at a steady rate. The batches sample the active series round-robin.
+type cardinalityDataProvider struct {
+	options testbed.LoadOptions
+	spec    CardinalitySpec
+
+	dataItemsGenerated *atomic.Uint64
+
+	// mutex orders the batches of parallel load generator workers, so that the samples of a series
+	// have increasing timestamps.
+	mutex     sync.Mutex
+	startTime time.Time
+	cursor    uint64
+}
+
+// NewCardinalityDataProvider creates a data provider of the series of the given spec.
+func NewCardinalityDataProvider(options testbed.LoadOptions, spec CardinalitySpec) testbed.DataProvider {
+	return &cardinalityDataProvider{
+		options: options,
+		spec:    spec,
+	}
+}
+
+func (dp *cardinalityDataProvider) SetLoadGeneratorCounters(dataItemsGenerated *atomic.Uint64) {
+	dp.dataItemsGenerated = dataItemsGenerated
+}
+
+func (dp *cardinalityDataProvider) GenerateTraces() (ptrace.Traces, bool) {
+	return ptrace.NewTraces(), true
+}
+
+func (dp *cardinalityDataProvider) GenerateLogs() (plog.Logs, bool) {
+	return plog.NewLogs(), true
+}
+
+func (dp *cardinalityDataProvider) GenerateMetrics() (pmetric.Metrics, bool) {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	now := time.Now()
+	if dp.startTime.IsZero() {
+		dp.startTime = now
+	}
+	offset := uint64(now.Sub(dp.startTime).Minutes() * float64(dp.spec.ChurnPerMinute))
+	timestamp := pcommon.NewTimestampFromTime(now)
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	for k, v := range dp.options.Attributes {
+		rm.Resource().Attributes().PutStr(k, v)
+	}
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+
+	// Data points are grouped by metric name in the order the names first occur in the batch.
+	byMetric := map[int]pmetric.NumberDataPointSlice{}
+	for i := 0; i < dp.options.ItemsPerBatch; i++ {
+		id := offset + dp.cursor%uint64(dp.spec.Series)
+		dp.cursor++
+
+		metricIndex := int(id % uint64(dp.spec.Metrics))
+		dps, ok := byMetric[metricIndex]
+		if !ok {
+			metric := metrics.AppendEmpty()
+			metric.SetName("load_generator_series_" + strconv.Itoa(metricIndex))
+			metric.SetDescription("Load Generator Series #" + strconv.Itoa(metricIndex))
+			dps = metric.SetEmptyGauge().DataPoints()
+			byMetric[metricIndex] = dps
+		}
+
+		dataPoint := dps.AppendEmpty()
+		dataPoint.SetTimestamp(timestamp)
+		dataPoint.SetDoubleValue(float64(dp.cursor))
+		dp.setSeriesAttributes(dataPoint.Attributes(), id)
+		if dp.dataItemsGenerated != nil {
+			dp.dataItemsGenerated.Add(1)
+		}
+	}
+	return md, false
+}
+
+// setSeriesAttributes sets the series_id and the label values of the series id, each label cycling
+// through its values with a different period.
+func (dp *cardinalityDataProvider) setSeriesAttributes(attrs pcommon.Map, id uint64) {
+	attrs.PutStr("series_id", strconv.FormatUint(id, 10))
+	rest := id
+	for _, label := range dp.spec.Labels {
+		values := uint64(label.Values)
+		attrs.PutStr(label.Name, label.Name+"_"+strconv.FormatUint(rest%values, 10))
+		rest /= values
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// seriesIDs returns the series_id of every data point of md by metric name.
+func seriesIDs(md pmetric.Metrics) map[string]string {
+	ids := map[string]string{}
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		dps := metrics.At(i).Gauge().DataPoints()
+		for j := 0; j < dps.Len(); j++ {
+			id, _ := dps.At(j).Attributes().Get("series_id")
+			ids[id.Str()] = metrics.At(i).Name()
+		}
+	}
+	return ids
+}
+
+func TestCardinalityDataProvider(t *testing.T) {
+	spec := CardinalitySpec{
+		Series:         20,
+		Metrics:        4,
+		Labels:         []LabelCardinality{{Name: "pod", Values: 5}, {Name: "endpoint", Values: 2}},
+		ChurnPerMinute: 60,
+	}
+	provider := NewCardinalityDataProvider(testbed.LoadOptions{ItemsPerBatch: 10}, spec).(*cardinalityDataProvider)
+	var generated atomic.Uint64
+	provider.SetLoadGeneratorCounters(&generated)
+
+	seen := map[string]string{}
+	for i := 0; i < 4; i++ {
+		md, _ := provider.GenerateMetrics()
+		for id, metric := range seriesIDs(md) {
+			seen[id] = metric
+		}
+	}
+	if len(seen) != 20 || generated.Load() != 40 {
+		t.Fatalf("got %d series and %d items, want the 20 active series sampled twice", len(seen), generated.Load())
+	}
+	if seen["7"] != "load_generator_series_3" {
+		t.Errorf("series 7 is in metric %s", seen["7"])
+	}
+
+	md, _ := provider.GenerateMetrics()
+	attrs := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Attributes()
+	if pod, _ := attrs.Get("pod"); pod.Str() != "pod_0" {
+		t.Errorf("got pod %s for series 0", pod.Str())
+	}
+
+	// After a minute 60 series are replaced, the active ones are 60 to 79.
+	provider.startTime = provider.startTime.Add(-time.Minute)
+	md, _ = provider.GenerateMetrics()
+	for id := range seriesIDs(md) {
+		if id < "60" || id > "79" {
+			t.Errorf("retired series %s still sampled", id)
+		}
+	}
+}
+
+func TestCardinalityLoadSpec(t *testing.T) {
+	load := DefaultScenarioSpec().Load
+	load.Provider = "cardinality"
+	load.Cardinality.Series = 50
+	if _, err := load.dataProvider(); err == nil {
+		t.Error("expected error for more items per batch than series")
+	}
+	load.Cardinality.Series = 1000
+	load.Cardinality.Labels = []LabelCardinality{{Name: "pod"}}
+	if _, err := load.dataProvider(); err == nil {
+		t.Error("expected error for label without values")
+	}
+}
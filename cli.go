@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/prometheus/common/model"
+)
+
+// IngestionMode selects how the collector forwards the generated metrics.
+type IngestionMode string
+
+const (
+	// ModeMock exports to the mock backend only, Prometheus does not receive any load.
+	ModeMock IngestionMode = "mock"
+	// ModeRemoteWrite exports through the prometheusremotewrite exporter to /api/v1/write.
+	ModeRemoteWrite IngestionMode = "remote-write"
+	// ModeOTLPNative exports through the otlphttp exporter to the native /api/v1/otlp endpoint.
+	ModeOTLPNative IngestionMode = "otlp-native"
+)
+
+// String implements flag.Value.
+func (m *IngestionMode) String() string {
+	return string(*m)
+}
+
+// Set implements flag.Value and rejects unknown modes.
+func (m *IngestionMode) Set(value string) error {
+	switch mode := IngestionMode(value); mode {
+	case ModeMock, ModeRemoteWrite, ModeOTLPNative:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q, expecting one of %s, %s, %s", value, ModeMock, ModeRemoteWrite, ModeOTLPNative)
+	}
+}
+
+const usage = `Usage: otlp_prometheus <command> [flags]
+
+Commands:
+  run      run one ingestion scenario against the collector and Prometheus
+  matrix   run the scenario for every combination of rate, batch size, parallelism and mode
+  conformance
+           check the names of the series OTLP metrics are stored as, for every ingestion mode
+  resource-attributes
+           check which resource attributes become labels and which only live in target_info
+  native-histograms
+           compare exponential histograms stored as native histograms with classic histograms
+  help     print this help
+
+Run "otlp_prometheus <command> -h" for the flags of a command.
+`
+
+// runCommand dispatches the command line arguments (without the program name) to the matching command.
+func runCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return errors.New("no command given")
+	}
+
+	switch args[0] {
+	case "run":
+		spec, err := parseRunFlags(args[1:], os.Stderr)
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resultsSummary := &ScenarioResults{}
+		resultsSummary.Init(path.Join("results", spec.Name))
+		result := sendToPrometheus(spec, resultsSummary)
+		resultsSummary.Save()
+		if result.ErrorCause != "" {
+			return fmt.Errorf("scenario %s failed: %s", spec.Name, result.ErrorCause)
+		}
+		return nil
+	case "matrix":
+		spec, err := parseMatrixFlags(args[1:], os.Stderr)
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = runMatrix(spec)
+		return err
+	case "conformance":
+		spec, err := parseConformanceFlags(args[1:], os.Stderr)
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		report, err := runConformance(spec)
+		if err != nil {
+			return err
+		}
+		if failed := report.Failed(); failed > 0 {
+			return fmt.Errorf("%d conformance cases failed", failed)
+		}
+		return nil
+	case "resource-attributes":
+		spec, err := parseRunFlags(args[1:], os.Stderr)
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		report, err := runResourceAttributes(spec)
+		if err != nil {
+			return err
+		}
+		if failed := report.Failed(); failed > 0 {
+			return fmt.Errorf("%d resource attributes not stored as expected", failed)
+		}
+		return nil
+	case "native-histograms":
+		spec, err := parseRunFlags(args[1:], os.Stderr)
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = runNativeHistograms(spec)
+		return err
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// parseRunFlags parses the flags of the "run" command. The scenario file, if given, replaces the built-in
+// defaults and flags given explicitly override the values of either.
+func parseRunFlags(args []string, output io.Writer) (ScenarioSpec, error) {
+	return parseScenarioFlags("run", args, output, nil)
+}
+
+// parseMatrixFlags parses the flags of the "matrix" command, the "run" flags plus one flag per axis.
+// An axis given as flag replaces the one of the scenario file.
+func parseMatrixFlags(args []string, output io.Writer) (ScenarioSpec, error) {
+	var (
+		rates      intList
+		batchSizes intList
+		parallels  intList
+		modes      modeList
+	)
+	return parseScenarioFlags("matrix", args, output, func(fs *flag.FlagSet) func(*flag.Flag, *ScenarioSpec) {
+		fs.Var(&rates, "rates", "comma-separated data points per second to sweep")
+		fs.Var(&batchSizes, "batch-sizes", "comma-separated batch sizes to sweep")
+		fs.Var(&parallels, "parallels", "comma-separated sender goroutine counts to sweep")
+		fs.Var(&modes, "modes", "comma-separated ingestion modes to sweep")
+
+		return func(f *flag.Flag, spec *ScenarioSpec) {
+			switch f.Name {
+			case "rates":
+				spec.Matrix.DataItemsPerSecond = rates
+			case "batch-sizes":
+				spec.Matrix.ItemsPerBatch = batchSizes
+			case "parallels":
+				spec.Matrix.Parallel = parallels
+			case "modes":
+				spec.Matrix.Modes = modes
+			}
+		}
+	})
+}
+
+// parseConformanceFlags parses the flags of the "conformance" command, the "run" flags plus the modes
+// to check, remote-write and otlp-native by default.
+func parseConformanceFlags(args []string, output io.Writer) (ScenarioSpec, error) {
+	var modes modeList
+	return parseScenarioFlags("conformance", args, output, func(fs *flag.FlagSet) func(*flag.Flag, *ScenarioSpec) {
+		fs.Var(&modes, "modes", "comma-separated ingestion modes to check")
+
+		return func(f *flag.Flag, spec *ScenarioSpec) {
+			if f.Name == "modes" {
+				spec.Matrix.Modes = modes
+			}
+		}
+	})
+}
+
+// parseScenarioFlags parses the flags shared by the commands running scenarios. register, if not nil,
+// adds command specific flags and returns the function applying them to the spec when given explicitly.
+func parseScenarioFlags(
+	name string,
+	args []string,
+	output io.Writer,
+	register func(fs *flag.FlagSet) func(*flag.Flag, *ScenarioSpec),
+) (ScenarioSpec, error) {
+	defaults := DefaultScenarioSpec()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	scenarioFile := fs.String("scenario", "", "YAML or JSON scenario file")
+	mode := defaults.Mode
+	fs.Var(&mode, "mode", "ingestion path: mock, remote-write or otlp-native")
+	collectorExePath := fs.String("collector", defaults.Collector.ExePath, "path to the collector executable")
+	prometheusExePath := fs.String("prometheus", defaults.Prometheus.ExePath, "path to the Prometheus executable")
+	receiverPort := fs.Int("receiver-port", defaults.Sender.Port, "port of the collector OTLP receiver, 0 for a free port")
+	exporterPort := fs.Int("exporter-port", defaults.Receiver.Port, "port of the mock backend, 0 for a free port")
+	prometheusPort := fs.Int("prometheus-port", defaults.Prometheus.Port, "port Prometheus listens on, 0 for a free port")
+	rate := fs.Int("rate", defaults.Load.DataItemsPerSecond, "data points generated per second")
+	batchSize := fs.Int("batch-size", defaults.Load.ItemsPerBatch, "data points per batch")
+	parallel := fs.Int("parallel", defaults.Load.Parallel, "number of goroutines sending load")
+	series := fs.Int("series", defaults.Load.Cardinality.Series, "active series of the cardinality provider")
+	churn := fs.Int("churn", defaults.Load.Cardinality.ChurnPerMinute, "series replaced per minute by the cardinality provider")
+	provider := fs.String("provider", defaults.Load.Provider, "data provider of the load: perf, histograms or cardinality")
+	duration := fs.Duration("duration", defaults.Duration, "duration of the load, defaults to TEST_DURATION or 15s")
+	validateData := fs.Bool("validate", defaults.ValidateData, "check that Prometheus stored all generated data")
+	keepData := fs.Bool("keep-data", defaults.Prometheus.KeepData, "keep the Prometheus TSDB in the results directory")
+	delay := fs.Duration("delay", defaults.Load.OutOfOrder.Delay, "shift the timestamps of all generated data into the past")
+	oooRatio := fs.Float64("ooo-ratio", defaults.Load.OutOfOrder.Ratio, "share of batches sent out of order, shifted back by up to a minute")
+	exemplars := fs.Bool("exemplars", defaults.Load.Histograms.Exemplars, "attach exemplars to the histograms and sums and enable the exemplar storage")
+	nativeHistograms := fs.Bool("native-histograms", defaults.Prometheus.NativeHistograms, "start Prometheus with the native histograms feature")
+	restartAfter := fs.Duration("restart-after", defaults.Prometheus.Restart.After, "restart Prometheus this long after the load started, 0 to keep it running; duplicated samples and the retries of prometheusremotewrite are reported unavailable")
+	restartDowntime := fs.Duration("restart-downtime", defaults.Prometheus.Restart.Downtime, "time Prometheus stays stopped when restarted")
+	oooWindow := fs.Duration("ooo-window", defaults.Prometheus.outOfOrderWindow(), "out_of_order_time_window of the Prometheus TSDB, 0 to reject out-of-order samples")
+	var apply func(*flag.Flag, *ScenarioSpec)
+	if register != nil {
+		apply = register(fs)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return defaults, err
+	}
+	if fs.NArg() > 0 {
+		return defaults, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	spec := defaults
+	if *scenarioFile != "" {
+		var err error
+		if spec, err = LoadScenarioSpec(*scenarioFile); err != nil {
+			return spec, err
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mode":
+			spec.Mode = mode
+		case "collector":
+			spec.Collector.ExePath = *collectorExePath
+		case "prometheus":
+			spec.Prometheus.ExePath = *prometheusExePath
+		case "receiver-port":
+			spec.Sender.Port = *receiverPort
+		case "exporter-port":
+			spec.Receiver.Port = *exporterPort
+		case "prometheus-port":
+			spec.Prometheus.Port = *prometheusPort
+		case "rate":
+			spec.Load.DataItemsPerSecond = *rate
+		case "batch-size":
+			spec.Load.ItemsPerBatch = *batchSize
+		case "parallel":
+			spec.Load.Parallel = *parallel
+		case "provider":
+			spec.Load.Provider = *provider
+		case "series":
+			spec.Load.Cardinality.Series = *series
+		case "churn":
+			spec.Load.Cardinality.ChurnPerMinute = *churn
+		case "duration":
+			spec.Duration = *duration
+		case "validate":
+			spec.ValidateData = *validateData
+		case "keep-data":
+			spec.Prometheus.KeepData = *keepData
+		case "delay":
+			spec.Load.OutOfOrder.Delay = *delay
+		case "ooo-ratio":
+			spec.Load.OutOfOrder.Ratio = *oooRatio
+		case "ooo-window":
+			if spec.Prometheus.Config.Storage == nil {
+				spec.Prometheus.Config.Storage = &StorageConfig{}
+			}
+			spec.Prometheus.Config.Storage.TSDB = &TSDBConfig{OutOfOrderTimeWindow: model.Duration(*oooWindow)}
+		case "exemplars":
+			spec.Load.Histograms.Exemplars = *exemplars
+			spec.Prometheus.ExemplarStorage = *exemplars
+		case "native-histograms":
+			spec.Prometheus.NativeHistograms = *nativeHistograms
+		case "restart-after":
+			spec.Prometheus.Restart.After = *restartAfter
+		case "restart-downtime":
+			spec.Prometheus.Restart.Downtime = *restartDowntime
+		default:
+			if apply != nil {
+				apply(f, &spec)
+			}
+		}
+	})
+
+	return spec, spec.Validate()
+}
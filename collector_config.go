@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"gopkg.in/yaml.v3"
+)
+
+// Component is a named collector component with its config.
+type Component struct {
+	Name   string
+	Config interface{}
+}
+
+// ComponentList holds collector components in the order they are listed in the scenario file, which
+// is the order of the processors in the pipeline. It is read from a YAML mapping of component names
+// to their config, e.g. "batch: {send_batch_size: 1000}".
+type ComponentList []Component
+
+func (l *ComponentList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Tag == "!!null" {
+		*l = nil
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of component names to their config", node.Line)
+	}
+
+	components := make(ComponentList, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var config interface{}
+		if err := node.Content[i+1].Decode(&config); err != nil {
+			return err
+		}
+		components = append(components, Component{Name: node.Content[i].Value, Config: config})
+	}
+	*l = components
+	return nil
+}
+
+// CollectorConfig models the config of the collector under test with a single pipeline. Components
+// are added with the Add methods and listed in the pipeline in the order they were added. Marshaled
+// component sections are sorted by name, so the same config always renders to the same YAML.
+type CollectorConfig struct {
+	Receivers  map[string]interface{} `yaml:"receivers"`
+	Processors map[string]interface{} `yaml:"processors,omitempty"`
+	Exporters  map[string]interface{} `yaml:"exporters"`
+	Extensions map[string]interface{} `yaml:"extensions,omitempty"`
+	Service    CollectorService       `yaml:"service"`
+
+	// pipeline is the data type of the pipeline: traces, metrics or logs.
+	pipeline string
+}
+
+// CollectorService is the service section of the collector config.
+type CollectorService struct {
+	Telemetry  CollectorTelemetry           `yaml:"telemetry"`
+	Extensions []string                     `yaml:"extensions,omitempty"`
+	Pipelines  map[string]CollectorPipeline `yaml:"pipelines"`
+}
+
+// CollectorTelemetry configures the internal telemetry of the collector.
+type CollectorTelemetry struct {
+	Metrics CollectorTelemetryMetrics `yaml:"metrics"`
+}
+
+// CollectorTelemetryMetrics configures the internal metrics endpoint of the collector.
+type CollectorTelemetryMetrics struct {
+	Level   string `yaml:"level,omitempty"`
+	Address string `yaml:"address,omitempty"`
+}
+
+// CollectorPipeline lists the components of a pipeline by name.
+type CollectorPipeline struct {
+	Receivers  []string `yaml:"receivers"`
+	Processors []string `yaml:"processors,omitempty"`
+	Exporters  []string `yaml:"exporters"`
+}
+
+// NewCollectorConfig creates an empty config with a pipeline of the given data type.
+func NewCollectorConfig(pipeline string) *CollectorConfig {
+	return &CollectorConfig{
+		Receivers:  map[string]interface{}{},
+		Processors: map[string]interface{}{},
+		Exporters:  map[string]interface{}{},
+		Extensions: map[string]interface{}{},
+		Service: CollectorService{
+			Pipelines: map[string]CollectorPipeline{pipeline: {}},
+		},
+		pipeline: pipeline,
+	}
+}
+
+// pipelineOf returns the pipeline data type of the DataSender.
+func pipelineOf(sender testbed.DataSender) (string, error) {
+	switch sender.(type) {
+	case testbed.TraceDataSender:
+		return "traces", nil
+	case testbed.MetricDataSender:
+		return "metrics", nil
+	case testbed.LogDataSender:
+		return "logs", nil
+	default:
+		return "", errors.New("invalid DataSender type")
+	}
+}
+
+func (c *CollectorConfig) updatePipeline(update func(p *CollectorPipeline)) {
+	p := c.Service.Pipelines[c.pipeline]
+	update(&p)
+	c.Service.Pipelines[c.pipeline] = p
+}
+
+// AddReceiver adds a receiver to the config and the pipeline.
+func (c *CollectorConfig) AddReceiver(name string, config interface{}) *CollectorConfig {
+	c.Receivers[name] = config
+	c.updatePipeline(func(p *CollectorPipeline) { p.Receivers = appendName(p.Receivers, name) })
+	return c
+}
+
+// AddProcessor adds a processor to the config, after the processors already in the pipeline.
+func (c *CollectorConfig) AddProcessor(name string, config interface{}) *CollectorConfig {
+	c.Processors[name] = config
+	c.updatePipeline(func(p *CollectorPipeline) { p.Processors = appendName(p.Processors, name) })
+	return c
+}
+
+// AddExporter adds an exporter to the config and the pipeline.
+func (c *CollectorConfig) AddExporter(name string, config interface{}) *CollectorConfig {
+	c.Exporters[name] = config
+	c.updatePipeline(func(p *CollectorPipeline) { p.Exporters = appendName(p.Exporters, name) })
+	return c
+}
+
+// AddExtension adds an extension to the config and enables it in the service.
+func (c *CollectorConfig) AddExtension(name string, config interface{}) *CollectorConfig {
+	c.Extensions[name] = config
+	c.Service.Extensions = appendName(c.Service.Extensions, name)
+	return c
+}
+
+// SetTelemetry exposes the internal metrics of the collector on localhost:port at the given level.
+func (c *CollectorConfig) SetTelemetry(port int, level string) *CollectorConfig {
+	c.Service.Telemetry.Metrics = CollectorTelemetryMetrics{
+		Level:   level,
+		Address: fmt.Sprintf("localhost:%d", port),
+	}
+	return c
+}
+
+// appendName appends name unless already present, re-adding a component replaces its config only.
+func appendName(names []string, name string) []string {
+	if containsToken(names, name) {
+		return names
+	}
+	return append(names, name)
+}
+
+// AddDataSender adds the receiver the DataSender sends to, using the config generated by the DataSender.
+func (c *CollectorConfig) AddDataSender(sender testbed.DataSender) error {
+	config, err := testbedComponentConfig(sender.GenConfigYAMLStr(), sender.ProtocolName())
+	if err != nil {
+		return fmt.Errorf("invalid receiver config of %s: %w", sender.ProtocolName(), err)
+	}
+	c.AddReceiver(sender.ProtocolName(), config)
+	return nil
+}
+
+// AddDataReceiver adds the exporter to the DataReceiver, using the config generated by the DataReceiver.
+func (c *CollectorConfig) AddDataReceiver(receiver testbed.DataReceiver) error {
+	config, err := testbedComponentConfig(receiver.GenConfigYAMLStr(), receiver.ProtocolName())
+	if err != nil {
+		return fmt.Errorf("invalid exporter config of %s: %w", receiver.ProtocolName(), err)
+	}
+	c.AddExporter(receiver.ProtocolName(), config)
+	return nil
+}
+
+// testbedComponentConfig extracts the config of the component name from the YAML section generated by
+// a testbed DataSender or DataReceiver.
+func testbedComponentConfig(section string, name string) (interface{}, error) {
+	var components map[string]interface{}
+	if err := yaml.Unmarshal([]byte(section), &components); err != nil {
+		return nil, err
+	}
+	config, ok := components[name]
+	if !ok || len(components) != 1 {
+		return nil, fmt.Errorf("expected a single %s section in %q", name, section)
+	}
+	return config, nil
+}
+
+// YAML renders the config.
+func (c *CollectorConfig) YAML() (string, error) {
+	return encodeYAML(c)
+}
+
+// encodeYAML renders v indented by two spaces, like the collector and Prometheus docs.
+func encodeYAML(v interface{}) (string, error) {
+	var out strings.Builder
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// prometheusExporterName returns the name of the collector exporter to Prometheus in mode, empty in mock mode.
+func prometheusExporterName(mode IngestionMode) string {
+	switch mode {
+	case ModeRemoteWrite:
+		return "prometheusremotewrite"
+	case ModeOTLPNative:
+		return "otlphttp/prometheus"
+	default:
+		return ""
+	}
+}
+
+// remoteWriteExporterConfig is the config of the prometheusremotewrite exporter writing to the Prometheus
+// listening on promPort. resourceToTelemetry adds the resource attributes as labels to every series.
+func remoteWriteExporterConfig(promPort int, resourceToTelemetry bool) map[string]interface{} {
+	return map[string]interface{}{
+		"endpoint": fmt.Sprintf("http://localhost:%d/api/v1/write", promPort),
+		"external_labels": map[string]interface{}{
+			"scenario": "otlp_prometheus_remote_write",
+		},
+		"export_created_metric": map[string]interface{}{
+			"enabled": true,
+		},
+		"resource_to_telemetry_conversion": map[string]interface{}{
+			"enabled": resourceToTelemetry,
+		},
+	}
+}
+
+// otlpNativeExporterConfig is the config of the otlphttp exporter writing to the OTLP receiver of the
+// Prometheus listening on promPort.
+func otlpNativeExporterConfig(promPort int) map[string]interface{} {
+	return map[string]interface{}{
+		"endpoint": fmt.Sprintf("http://localhost:%d/api/v1/otlp", promPort),
+		"tls": map[string]interface{}{
+			"insecure": true,
+		},
+	}
+}
+
+// withSettings sets the top-level settings of an exporter config, replacing those of the same name.
+func withSettings(config map[string]interface{}, settings map[string]interface{}) map[string]interface{} {
+	for key, value := range settings {
+		config[key] = value
+	}
+	return config
+}
+
+// deltaToCumulativeProcessor converts delta sums and histograms to cumulative ones, which are the only
+// temporality Prometheus stores. It is not part of the contrib distribution v0.85, the collector config
+// check fails with collectors built before it was added.
+const deltaToCumulativeProcessor = "deltatocumulative"
+
+// newCollectorConfig composes the collector config of the scenario: the receiver of the sender, the
+// processors of the spec followed by the deltatocumulative processor if enabled, the exporter to the
+// mock backend and, depending on the mode, the exporter to Prometheus together with the logging exporter.
+func newCollectorConfig(spec ScenarioSpec, sender testbed.DataSender, receiver testbed.DataReceiver, resultDir string) (*CollectorConfig, error) {
+	pipeline, err := pipelineOf(sender)
+	if err != nil {
+		return nil, err
+	}
+
+	// Note that our DataSender is used to generate a config for Collector's receiver and our
+	// DataReceiver is used to generate a config for Collector's exporter. This is because our
+	// DataSender sends to Collector's receiver and our DataReceiver receives from Collector's exporter.
+	config := NewCollectorConfig(pipeline)
+	config.SetTelemetry(spec.Collector.TelemetryPort, "detailed")
+	if err = config.AddDataSender(sender); err != nil {
+		return nil, err
+	}
+	for _, processor := range spec.Collector.Processors {
+		config.AddProcessor(processor.Name, processor.Config)
+	}
+	if spec.Collector.DeltaToCumulative {
+		config.AddProcessor(deltaToCumulativeProcessor, nil)
+	}
+	if err = config.AddDataReceiver(receiver); err != nil {
+		return nil, err
+	}
+
+	switch spec.Mode {
+	case ModeRemoteWrite:
+		exporter := remoteWriteExporterConfig(spec.Prometheus.Port, spec.Collector.ResourceToTelemetry)
+		config.AddExporter(prometheusExporterName(spec.Mode), withSettings(exporter, spec.Collector.Exporters[spec.Mode]))
+		config.AddExporter("logging", nil)
+	case ModeOTLPNative:
+		exporter := otlpNativeExporterConfig(spec.Prometheus.Port)
+		config.AddExporter(prometheusExporterName(spec.Mode), withSettings(exporter, spec.Collector.Exporters[spec.Mode]))
+		config.AddExporter("logging", nil)
+	}
+
+	pprof := map[string]interface{}{
+		"save_to_file": resultDir + "/cpu.prof",
+	}
+	if spec.Collector.PprofPort != 0 {
+		pprof["endpoint"] = fmt.Sprintf("localhost:%d", spec.Collector.PprofPort)
+	}
+	config.AddExtension("pprof", pprof)
+	for _, extension := range spec.Collector.Extensions {
+		config.AddExtension(extension.Name, extension.Config)
+	}
+	return config, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewCollectorConfig(t *testing.T) {
+	for _, tc := range []struct {
+		mode      IngestionMode
+		exporters []string
+	}{
+		{mode: ModeMock, exporters: []string{"otlphttp"}},
+		{mode: ModeRemoteWrite, exporters: []string{"otlphttp", "prometheusremotewrite", "logging"}},
+		{mode: ModeOTLPNative, exporters: []string{"otlphttp", "otlphttp/prometheus", "logging"}},
+	} {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			spec := DefaultScenarioSpec()
+			spec.Mode = tc.mode
+			spec.Collector.TelemetryPort = 8888
+			spec.Collector.PprofPort = 1778
+			spec.Collector.Processors = ComponentList{
+				{Name: "memory_limiter", Config: map[string]interface{}{"limit_mib": 512}},
+				{Name: "batch"},
+			}
+			sender, _ := spec.Sender.build()
+			receiver, _ := spec.Receiver.build()
+
+			config, err := newCollectorConfig(spec, sender, receiver, "/tmp/results")
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := config.YAML()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var parsed struct {
+				Receivers  map[string]interface{} `yaml:"receivers"`
+				Processors map[string]interface{} `yaml:"processors"`
+				Exporters  map[string]interface{} `yaml:"exporters"`
+				Extensions map[string]interface{} `yaml:"extensions"`
+				Service    CollectorService       `yaml:"service"`
+			}
+			if err = yaml.Unmarshal([]byte(out), &parsed); err != nil {
+				t.Fatalf("invalid YAML %q: %s", out, err)
+			}
+
+			pipeline := parsed.Service.Pipelines["metrics"]
+			if strings.Join(pipeline.Receivers, ",") != "otlp" {
+				t.Errorf("got receivers %v", pipeline.Receivers)
+			}
+			if strings.Join(pipeline.Processors, ",") != "memory_limiter,batch" {
+				t.Errorf("got processors %v, want them in spec order", pipeline.Processors)
+			}
+			if strings.Join(pipeline.Exporters, ",") != strings.Join(tc.exporters, ",") {
+				t.Errorf("got exporters %v, want %v", pipeline.Exporters, tc.exporters)
+			}
+			for _, name := range pipeline.Exporters {
+				if _, ok := parsed.Exporters[name]; !ok {
+					t.Errorf("exporter %s has no config section", name)
+				}
+			}
+			if _, ok := parsed.Receivers["otlp"]; !ok {
+				t.Errorf("receiver otlp has no config section")
+			}
+			if strings.Join(parsed.Service.Extensions, ",") != "pprof" || parsed.Extensions["pprof"] == nil {
+				t.Errorf("got extensions %v", parsed.Service.Extensions)
+			} else if pprof := parsed.Extensions["pprof"].(map[string]interface{}); pprof["endpoint"] != "localhost:1778" {
+				t.Errorf("got pprof extension %v", pprof)
+			}
+			if parsed.Service.Telemetry.Metrics.Address != "localhost:8888" {
+				t.Errorf("got telemetry %+v", parsed.Service.Telemetry)
+			}
+
+			// The same spec always renders to the same YAML.
+			again, _ := newCollectorConfig(spec, sender, receiver, "/tmp/results")
+			if out2, _ := again.YAML(); out2 != out {
+				t.Errorf("config is not deterministic:\n%s\n%s", out, out2)
+			}
+		})
+	}
+}
+
+func TestCollectorConfigDeltaToCumulative(t *testing.T) {
+	spec := DefaultScenarioSpec()
+	spec.Collector.Processors = ComponentList{{Name: "batch"}}
+	spec.Collector.DeltaToCumulative = true
+	sender, _ := spec.Sender.build()
+	receiver, _ := spec.Receiver.build()
+
+	config, err := newCollectorConfig(spec, sender, receiver, "/tmp/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processors := config.Service.Pipelines["metrics"].Processors; strings.Join(processors, ",") != "batch,deltatocumulative" {
+		t.Errorf("got processors %v", processors)
+	}
+}
+
+func TestCollectorConfigResourceToTelemetry(t *testing.T) {
+	spec := DefaultScenarioSpec()
+	spec.Mode = ModeRemoteWrite
+	spec.Collector.ResourceToTelemetry = true
+	sender, _ := spec.Sender.build()
+	receiver, _ := spec.Receiver.build()
+
+	config, err := newCollectorConfig(spec, sender, receiver, "/tmp/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := config.Exporters["prometheusremotewrite"].(map[string]interface{})
+	if conversion := exporter["resource_to_telemetry_conversion"].(map[string]interface{}); conversion["enabled"] != true {
+		t.Errorf("got resource_to_telemetry_conversion %v", conversion)
+	}
+}
+
+func TestCollectorConfigExporterSettings(t *testing.T) {
+	spec := DefaultScenarioSpec()
+	spec.Mode = ModeOTLPNative
+	spec.Collector.Exporters = map[IngestionMode]map[string]interface{}{
+		ModeOTLPNative:  {"sending_queue": map[string]interface{}{"queue_size": 10}},
+		ModeRemoteWrite: {"remote_write_queue": map[string]interface{}{"queue_size": 10}},
+	}
+	sender, _ := spec.Sender.build()
+	receiver, _ := spec.Receiver.build()
+
+	config, err := newCollectorConfig(spec, sender, receiver, "/tmp/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := config.Exporters["otlphttp/prometheus"].(map[string]interface{})
+	if exporter["sending_queue"] == nil || exporter["remote_write_queue"] != nil || exporter["endpoint"] == nil {
+		t.Errorf("got exporter config %v", exporter)
+	}
+}
+
+func TestCollectorConfigWithoutProcessors(t *testing.T) {
+	config := NewCollectorConfig("metrics").
+		AddReceiver("otlp", nil).
+		AddExporter("logging", nil)
+	out, err := config.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "processors") || strings.Contains(out, "extensions") {
+		t.Errorf("empty sections rendered:\n%s", out)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// collectorSelfMetrics are the series of the collector's internal telemetry telling whether the
+// exporters to Prometheus keep up: points sent and failed, the sending queue and the points accepted
+// and refused by the receivers.
+var collectorSelfMetrics = []metricSelector{
+	{name: "otelcol_exporter_sent_metric_points"},
+	{name: "otelcol_exporter_send_failed_metric_points"},
+	{name: "otelcol_exporter_enqueue_failed_metric_points"},
+	{name: "otelcol_exporter_queue_size"},
+	{name: "otelcol_exporter_queue_capacity"},
+	{name: "otelcol_receiver_accepted_metric_points"},
+	{name: "otelcol_receiver_refused_metric_points"},
+	{name: "otelcol_processor_dropped_metric_points"},
+	{name: "otelcol_process_runtime_heap_alloc_bytes"},
+}
+
+// ExporterStats are the final metric point counters of one collector exporter.
+type ExporterStats struct {
+	Exporter            string  `json:"exporter"`
+	SentPoints          float64 `json:"sent_points"`
+	SendFailedPoints    float64 `json:"send_failed_points"`
+	EnqueueFailedPoints float64 `json:"enqueue_failed_points"`
+	QueueSize           float64 `json:"queue_size"`
+	QueueCapacity       float64 `json:"queue_capacity"`
+}
+
+// ReceiverStats are the final metric point counters of one collector receiver.
+type ReceiverStats struct {
+	Receiver       string  `json:"receiver"`
+	AcceptedPoints float64 `json:"accepted_points"`
+	RefusedPoints  float64 `json:"refused_points"`
+}
+
+// newExporterStats aggregates the last scraped collector samples by exporter, sorted by exporter.
+func newExporterStats(samples []ScrapedSample) []ExporterStats {
+	byExporter := map[string]*ExporterStats{}
+	for _, sample := range samples {
+		exporter, ok := sample.Labels["exporter"]
+		if !ok {
+			continue
+		}
+		stats, ok := byExporter[exporter]
+		if !ok {
+			stats = &ExporterStats{Exporter: exporter}
+			byExporter[exporter] = stats
+		}
+		switch sample.Name {
+		case "otelcol_exporter_sent_metric_points":
+			stats.SentPoints += sample.Value
+		case "otelcol_exporter_send_failed_metric_points":
+			stats.SendFailedPoints += sample.Value
+		case "otelcol_exporter_enqueue_failed_metric_points":
+			stats.EnqueueFailedPoints += sample.Value
+		case "otelcol_exporter_queue_size":
+			stats.QueueSize += sample.Value
+		case "otelcol_exporter_queue_capacity":
+			stats.QueueCapacity += sample.Value
+		}
+	}
+
+	result := make([]ExporterStats, 0, len(byExporter))
+	for _, stats := range byExporter {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Exporter < result[j].Exporter
+	})
+	return result
+}
+
+// newReceiverStats aggregates the last scraped collector samples by receiver, sorted by receiver.
+func newReceiverStats(samples []ScrapedSample) []ReceiverStats {
+	byReceiver := map[string]*ReceiverStats{}
+	for _, sample := range samples {
+		receiver, ok := sample.Labels["receiver"]
+		if !ok {
+			continue
+		}
+		stats, ok := byReceiver[receiver]
+		if !ok {
+			stats = &ReceiverStats{Receiver: receiver}
+			byReceiver[receiver] = stats
+		}
+		switch sample.Name {
+		case "otelcol_receiver_accepted_metric_points":
+			stats.AcceptedPoints += sample.Value
+		case "otelcol_receiver_refused_metric_points":
+			stats.RefusedPoints += sample.Value
+		}
+	}
+
+	result := make([]ReceiverStats, 0, len(byReceiver))
+	for _, stats := range byReceiver {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Receiver < result[j].Receiver
+	})
+	return result
+}
+
+func (s ExporterStats) String() string {
+	return fmt.Sprintf("exporter %s sent:%.0f send failed:%.0f enqueue failed:%.0f queue:%.0f/%.0f",
+		s.Exporter, s.SentPoints, s.SendFailedPoints, s.EnqueueFailedPoints, s.QueueSize, s.QueueCapacity)
+}
+
+func (s ReceiverStats) String() string {
+	return fmt.Sprintf("receiver %s accepted:%.0f refused:%.0f", s.Receiver, s.AcceptedPoints, s.RefusedPoints)
+}
+
+// logCollectorStats logs the point counters of every receiver and exporter, with a warning for
+// exporters that failed to send points.
+func logCollectorStats(receivers []ReceiverStats, exporters []ExporterStats) {
+	for _, stats := range receivers {
+		log.Printf("Collector %s", stats)
+	}
+	for _, stats := range exporters {
+		log.Printf("Collector %s", stats)
+		if stats.SendFailedPoints > 0 || stats.EnqueueFailedPoints > 0 {
+			log.Printf("Collector exporter %s dropped %.0f metric points", stats.Exporter, stats.SendFailedPoints+stats.EnqueueFailedPoints)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+const testCollectorMetrics = `# HELP otelcol_exporter_sent_metric_points Number of metric points successfully sent to destination.
+# TYPE otelcol_exporter_sent_metric_points counter
+otelcol_exporter_sent_metric_points{exporter="otlp",service_instance_id="a"} 1000
+otelcol_exporter_sent_metric_points{exporter="prometheusremotewrite",service_instance_id="a"} 900
+# HELP otelcol_exporter_send_failed_metric_points Number of metric points in failed attempts to send to destination.
+# TYPE otelcol_exporter_send_failed_metric_points counter
+otelcol_exporter_send_failed_metric_points{exporter="otlp",service_instance_id="a"} 0
+otelcol_exporter_send_failed_metric_points{exporter="prometheusremotewrite",service_instance_id="a"} 100
+# HELP otelcol_exporter_queue_size Current size of the retry queue (in batches)
+# TYPE otelcol_exporter_queue_size gauge
+otelcol_exporter_queue_size{exporter="prometheusremotewrite",service_instance_id="a"} 3
+# HELP otelcol_exporter_queue_capacity Fixed capacity of the retry queue (in batches)
+# TYPE otelcol_exporter_queue_capacity gauge
+otelcol_exporter_queue_capacity{exporter="prometheusremotewrite",service_instance_id="a"} 5000
+# HELP otelcol_receiver_accepted_metric_points Number of metric points successfully pushed into the pipeline.
+# TYPE otelcol_receiver_accepted_metric_points counter
+otelcol_receiver_accepted_metric_points{receiver="otlp",service_instance_id="a",transport="http"} 1000
+# HELP otelcol_receiver_refused_metric_points Number of metric points that could not be pushed into the pipeline.
+# TYPE otelcol_receiver_refused_metric_points counter
+otelcol_receiver_refused_metric_points{receiver="otlp",service_instance_id="a",transport="http"} 2
+`
+
+func TestCollectorStats(t *testing.T) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(testCollectorMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := selectSamples(families, collectorSelfMetrics, time.Now())
+
+	exporters := newExporterStats(samples)
+	wantExporters := []ExporterStats{
+		{Exporter: "otlp", SentPoints: 1000},
+		{Exporter: "prometheusremotewrite", SentPoints: 900, SendFailedPoints: 100, QueueSize: 3, QueueCapacity: 5000},
+	}
+	if len(exporters) != len(wantExporters) {
+		t.Fatalf("got %v, want %v", exporters, wantExporters)
+	}
+	for i := range wantExporters {
+		if exporters[i] != wantExporters[i] {
+			t.Errorf("got %v, want %v", exporters[i], wantExporters[i])
+		}
+	}
+
+	receivers := newReceiverStats(samples)
+	wantReceiver := ReceiverStats{Receiver: "otlp", AcceptedPoints: 1000, RefusedPoints: 2}
+	if len(receivers) != 1 || receivers[0] != wantReceiver {
+		t.Errorf("got %v, want [%v]", receivers, wantReceiver)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configCheckTimeout bounds the run time of the external config checkers.
+const configCheckTimeout = 30 * time.Second
+
+// lineNumberRegexp matches the line references of YAML errors, e.g. "line 12: field foo not found".
+var lineNumberRegexp = regexp.MustCompile(`line (\d+)`)
+
+// checkCollectorConfig checks the collector config before it is written for the collector: in-process
+// that it is valid YAML whose pipelines only reference defined components, then with "<exePath> validate"
+// which also checks the config of every component. The validate command is skipped if the collector
+// cannot be run.
+func checkCollectorConfig(config string, exePath string) error {
+	if err := checkCollectorPipelines(config); err != nil {
+		return withConfigExcerpt(err, config)
+	}
+	return runConfigChecker(config, "collectorConfig*.yaml", expandExeFileName(exePath), func(fileName string) []string {
+		return []string{"validate", "--config=" + fileName}
+	})
+}
+
+// checkPrometheusConfig checks the Prometheus config before it is written for Prometheus: in-process
+// that it is valid YAML of the fields supported by PrometheusConfig, then with "promtool check config".
+// promtool is looked up next to the Prometheus executable and in the PATH, the check is skipped if it
+// is not found.
+func checkPrometheusConfig(config string, exePath string) error {
+	if err := checkPrometheusConfigFields(config); err != nil {
+		return withConfigExcerpt(err, config)
+	}
+	return runConfigChecker(config, "prometheusConfig*.yaml", promtoolPath(exePath), func(fileName string) []string {
+		return []string{"check", "config", fileName}
+	})
+}
+
+// collectorPipelineRefs holds the component names referenced by the service, with their position.
+type collectorPipelineRefs struct {
+	Receivers  map[string]yaml.Node `yaml:"receivers"`
+	Processors map[string]yaml.Node `yaml:"processors"`
+	Exporters  map[string]yaml.Node `yaml:"exporters"`
+	Extensions map[string]yaml.Node `yaml:"extensions"`
+	Service    struct {
+		Extensions []yaml.Node `yaml:"extensions"`
+		Pipelines  map[string]struct {
+			Receivers  []yaml.Node `yaml:"receivers"`
+			Processors []yaml.Node `yaml:"processors"`
+			Exporters  []yaml.Node `yaml:"exporters"`
+		} `yaml:"pipelines"`
+	} `yaml:"service"`
+}
+
+func checkCollectorPipelines(config string) error {
+	var refs collectorPipelineRefs
+	if err := yaml.Unmarshal([]byte(config), &refs); err != nil {
+		return err
+	}
+
+	var errs []error
+	check := func(kind string, defined map[string]yaml.Node, names []yaml.Node) {
+		for _, name := range names {
+			if _, ok := defined[name.Value]; !ok {
+				errs = append(errs, fmt.Errorf("line %d: %s %q is not defined", name.Line, kind, name.Value))
+			}
+		}
+	}
+	if len(refs.Service.Pipelines) == 0 {
+		errs = append(errs, errors.New("service has no pipelines"))
+	}
+	for name, pipeline := range refs.Service.Pipelines {
+		if len(pipeline.Receivers) == 0 || len(pipeline.Exporters) == 0 {
+			errs = append(errs, fmt.Errorf("pipeline %s needs at least one receiver and one exporter", name))
+		}
+		check("receiver", refs.Receivers, pipeline.Receivers)
+		check("processor", refs.Processors, pipeline.Processors)
+		check("exporter", refs.Exporters, pipeline.Exporters)
+	}
+	check("extension", refs.Extensions, refs.Service.Extensions)
+	return errors.Join(errs...)
+}
+
+func checkPrometheusConfigFields(config string) error {
+	var parsed PrometheusConfig
+	decoder := yaml.NewDecoder(strings.NewReader(config))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&parsed); err != nil {
+		return err
+	}
+
+	var errs []error
+	jobs := map[string]bool{}
+	for _, sc := range parsed.ScrapeConfigs {
+		if sc.JobName == "" {
+			errs = append(errs, errors.New("scrape config without job_name"))
+		} else if jobs[sc.JobName] {
+			errs = append(errs, fmt.Errorf("duplicate scrape job %s", sc.JobName))
+		}
+		jobs[sc.JobName] = true
+	}
+	for _, rw := range parsed.RemoteWrite {
+		if rw.URL == "" {
+			errs = append(errs, errors.New("remote_write without url"))
+		}
+	}
+	for _, rr := range parsed.RemoteRead {
+		if rr.URL == "" {
+			errs = append(errs, errors.New("remote_read without url"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// promtoolPath returns the promtool next to the Prometheus executable, else the one in the PATH.
+func promtoolPath(prometheusExePath string) string {
+	promtool := filepath.Join(filepath.Dir(expandExeFileName(prometheusExePath)), "promtool")
+	if _, err := os.Stat(promtool); err == nil {
+		return promtool
+	}
+	if promtool, err := exec.LookPath("promtool"); err == nil {
+		return promtool
+	}
+	return ""
+}
+
+// runConfigChecker writes config to a temporary file and runs the checker executable with the arguments
+// returned by args for the file. A failed check returns the output of the checker with an excerpt of
+// the lines it refers to. The check is skipped if the checker cannot be run or does not know the command.
+func runConfigChecker(config string, pattern string, exePath string, args func(fileName string) []string) error {
+	if exePath == "" {
+		log.Printf("Config check with %s skipped, no checker found", pattern)
+		return nil
+	}
+
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.WriteString(config); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), configCheckTimeout)
+	defer cancel()
+	// #nosec
+	cmd := exec.CommandContext(ctx, exePath, args(file.Name())...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case !errors.As(err, &exitErr):
+		log.Printf("Config check with %s skipped: %s", exePath, err.Error())
+		return nil
+	case strings.Contains(output.String(), "unknown command"):
+		log.Printf("Config check skipped, %s does not support %q", exePath, strings.Join(args(file.Name()), " "))
+		return nil
+	}
+	return withConfigExcerpt(fmt.Errorf("%s: %s", filepath.Base(exePath), strings.TrimSpace(output.String())), config)
+}
+
+// withConfigExcerpt appends the numbered config lines the error refers to, with two lines of context.
+func withConfigExcerpt(err error, config string) error {
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(config, "\n")
+
+	var excerpt strings.Builder
+	shown := map[int]bool{}
+	for _, match := range lineNumberRegexp.FindAllStringSubmatch(err.Error(), -1) {
+		line, _ := strconv.Atoi(match[1])
+		if line < 1 || line > len(lines) || shown[line] {
+			continue
+		}
+		shown[line] = true
+		excerpt.WriteString("\n")
+		for i := line - 2; i <= line+2; i++ {
+			if i < 1 || i > len(lines) {
+				continue
+			}
+			marker := " "
+			if i == line {
+				marker = ">"
+			}
+			fmt.Fprintf(&excerpt, "%s%4d | %s\n", marker, i, lines[i-1])
+		}
+	}
+	if excerpt.Len() == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\n%s", err, excerpt.String())
+}
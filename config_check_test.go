@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCollectorConfig = `receivers:
+  otlp:
+exporters:
+  logging:
+service:
+  pipelines:
+    metrics:
+      receivers: [otlp]
+      exporters: [logging, prometheusremotewrite]
+`
+
+func TestCheckCollectorConfig(t *testing.T) {
+	err := checkCollectorConfig(testCollectorConfig, filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatal("expected error for undefined exporter")
+	}
+	if !strings.Contains(err.Error(), `line 9: exporter "prometheusremotewrite" is not defined`) ||
+		!strings.Contains(err.Error(), ">   9 |       exporters: [logging, prometheusremotewrite]") {
+		t.Errorf("unexpected error %q", err)
+	}
+
+	for _, mode := range []IngestionMode{ModeMock, ModeRemoteWrite, ModeOTLPNative} {
+		spec := DefaultScenarioSpec()
+		spec.Mode = mode
+		sender, _ := spec.Sender.build()
+		receiver, _ := spec.Receiver.build()
+		config, err := newCollectorConfig(spec, sender, receiver, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, _ := config.YAML()
+		if err = checkCollectorPipelines(out); err != nil {
+			t.Errorf("generated %s config is invalid: %s", mode, err)
+		}
+	}
+}
+
+func TestCheckPrometheusConfig(t *testing.T) {
+	config := "global:\n  scrape_interval: 15s\n  scrape_intervall: 5s\n"
+	err := checkPrometheusConfig(config, filepath.Join(t.TempDir(), "prometheus"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), ">   3 |   scrape_intervall: 5s") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	out, _ := newPrometheusConfig(DefaultScenarioSpec().Prometheus).YAML()
+	if err = checkPrometheusConfigFields(out); err != nil {
+		t.Errorf("generated config is invalid: %s", err)
+	}
+}
+
+func TestRunConfigChecker(t *testing.T) {
+	dir := t.TempDir()
+	checker := func(name, script string) string {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+	config := "a: 1\nb: 2\nc: 3\n"
+	args := func(fileName string) []string { return []string{"check", fileName} }
+
+	err := runConfigChecker(config, "test*.yaml", checker("failing", "echo 'line 2: field b not found'; exit 1\n"), args)
+	if err == nil || !strings.Contains(err.Error(), "failing: line 2: field b not found") || !strings.Contains(err.Error(), ">   2 | b: 2") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err = runConfigChecker(config, "test*.yaml", checker("passing", "test -f \"$2\"\n"), args); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err = runConfigChecker(config, "test*.yaml", checker("old", "echo 'Error: unknown command \"check\"'; exit 1\n"), args); err != nil {
+		t.Errorf("unknown command not skipped: %v", err)
+	}
+	if err = runConfigChecker(config, "test*.yaml", filepath.Join(dir, "missing"), args); err != nil {
+		t.Errorf("missing checker not skipped: %v", err)
+	}
+}
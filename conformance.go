@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// conformanceCaseLabel identifies the series of a conformance case whatever its translated name.
+const (
+	conformanceCaseAttribute = "conformance.case"
+	conformanceCaseLabel     = "conformance_case"
+)
+
+// conformanceResource are the resource attributes of all conformance metrics. service.namespace and
+// service.name make the job, service.instance.id the instance and the others go to target_info.
+var conformanceResource = map[string]string{
+	"service.name":        "conformance",
+	"service.namespace":   "bench",
+	"service.instance.id": "instance-1",
+	"host.name":           "bench-host",
+}
+
+// conformanceJob and conformanceInstance are the job and instance labels expected from conformanceResource.
+const (
+	conformanceJob      = "bench/conformance"
+	conformanceInstance = "instance-1"
+)
+
+// Metric types of the conformance cases.
+const (
+	conformanceGauge     = "gauge"
+	conformanceSum       = "sum"
+	conformanceCounter   = "counter"
+	conformanceHistogram = "histogram"
+)
+
+// conformanceCase is an OTLP metric and the Prometheus series our dashboards expect it to be stored as.
+type conformanceCase struct {
+	ID          string
+	Description string
+	// Name, Unit and Type describe the metric, Type is gauge, sum, counter (a monotonic sum) or histogram.
+	Name       string
+	Unit       string
+	Type       string
+	Attributes map[string]string
+	// WantName and WantLabels are the expected series, besides job, instance and conformance_case.
+	WantName   string
+	WantLabels map[string]string
+}
+
+// conformanceCases cover the translation of names, units, types and attributes.
+var conformanceCases = []conformanceCase{
+	{ID: "dots", Description: "dots in the name", Name: "http.server.active_requests", Unit: "{request}", Type: conformanceGauge,
+		WantName: "http_server_active_requests"},
+	{ID: "unit-ms", Description: "unit ms", Name: "rpc.client.duration", Unit: "ms", Type: conformanceGauge,
+		WantName: "rpc_client_duration_milliseconds"},
+	{ID: "unit-by", Description: "unit By of a non-monotonic sum", Name: "process.memory.usage", Unit: "By", Type: conformanceSum,
+		WantName: "process_memory_usage_bytes"},
+	{ID: "unit-1-gauge", Description: "unit 1 of a gauge", Name: "system.cpu.utilization", Unit: "1", Type: conformanceGauge,
+		WantName: "system_cpu_utilization_ratio"},
+	{ID: "counter", Description: "monotonic sum", Name: "http.server.requests", Unit: "{request}", Type: conformanceCounter,
+		WantName: "http_server_requests_total"},
+	{ID: "counter-by", Description: "monotonic sum with unit By", Name: "system.network.io", Unit: "By", Type: conformanceCounter,
+		WantName: "system_network_io_bytes_total"},
+	{ID: "counter-1", Description: "monotonic sum with unit 1", Name: "process.context_switches", Unit: "1", Type: conformanceCounter,
+		WantName: "process_context_switches_total"},
+	{ID: "counter-total", Description: "monotonic sum named total", Name: "jobs.total", Type: conformanceCounter,
+		WantName: "jobs_total"},
+	{ID: "per-unit", Description: "unit per second", Name: "http.server.request.rate", Unit: "{request}/s", Type: conformanceGauge,
+		WantName: "http_server_request_rate_per_second"},
+	{ID: "histogram-ms", Description: "histogram with unit ms", Name: "http.server.duration", Unit: "ms", Type: conformanceHistogram,
+		WantName: "http_server_duration_milliseconds_bucket", WantLabels: map[string]string{"le": "+Inf"}},
+	{ID: "leading-digit", Description: "name starting with a digit", Name: "5xx.responses", Type: conformanceGauge,
+		WantName: "_5xx_responses"},
+	{ID: "unicode-name", Description: "unicode letters in the name", Name: "température.moteur", Unit: "Cel", Type: conformanceGauge,
+		WantName: "température_moteur_celsius"},
+	{ID: "attributes", Description: "attribute sanitization and unicode values", Name: "conformance.attributes", Type: conformanceGauge,
+		Attributes: map[string]string{"http.request.method": "GET", "2xx.share": "high", "_private": "x", "site": "Île-de-France"},
+		WantName:   "conformance_attributes",
+		WantLabels: map[string]string{"http_request_method": "GET", "key_2xx_share": "high", "key_private": "x", "site": "Île-de-France"}},
+}
+
+// targetInfoCase is the target_info series expected for conformanceResource.
+var targetInfoCase = conformanceCase{
+	ID:          "target-info",
+	Description: "target_info of the resource",
+	WantName:    "target_info",
+	WantLabels:  map[string]string{"host_name": "bench-host"},
+}
+
+// metrics returns the metric of the case with one data point at ts.
+func (c conformanceCase) metrics(ts time.Time) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	for k, v := range conformanceResource {
+		rm.Resource().Attributes().PutStr(k, v)
+	}
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName(c.Name)
+	metric.SetUnit(c.Unit)
+
+	var attrs pcommon.Map
+	timestamp := pcommon.NewTimestampFromTime(ts)
+	switch c.Type {
+	case conformanceGauge:
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetTimestamp(timestamp)
+		dp.SetDoubleValue(1)
+		attrs = dp.Attributes()
+	case conformanceSum, conformanceCounter:
+		sum := metric.SetEmptySum()
+		sum.SetIsMonotonic(c.Type == conformanceCounter)
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		dp := sum.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(timestamp)
+		dp.SetTimestamp(timestamp)
+		dp.SetDoubleValue(1)
+		attrs = dp.Attributes()
+	case conformanceHistogram:
+		histogram := metric.SetEmptyHistogram()
+		histogram.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		dp := histogram.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(timestamp)
+		dp.SetTimestamp(timestamp)
+		dp.ExplicitBounds().FromRaw([]float64{10, 100})
+		dp.BucketCounts().FromRaw([]uint64{1, 1, 1})
+		dp.SetCount(3)
+		dp.SetSum(160)
+		attrs = dp.Attributes()
+	}
+	for k, v := range c.Attributes {
+		attrs.PutStr(k, v)
+	}
+	attrs.PutStr(conformanceCaseAttribute, c.ID)
+	return md
+}
+
+// want returns the expected series of the case in PromQL notation.
+func (c conformanceCase) want() string {
+	labels := map[string]string{"job": conformanceJob, "instance": conformanceInstance}
+	if c.ID != targetInfoCase.ID {
+		labels[conformanceCaseLabel] = c.ID
+	}
+	for k, v := range c.WantLabels {
+		labels[k] = v
+	}
+	return seriesKey(c.WantName, labels, nil)
+}
+
+// matcher returns the selector of all series of the case whatever their name.
+func (c conformanceCase) matcher() string {
+	if c.ID == targetInfoCase.ID {
+		return fmt.Sprintf("{__name__=%q,job=%q}", c.WantName, conformanceJob)
+	}
+	return fmt.Sprintf("{%s=%q}", conformanceCaseLabel, c.ID)
+}
+
+// matches returns true if labels, including __name__, are the expected series.
+func (c conformanceCase) matches(labels map[string]string) bool {
+	if labels["__name__"] != c.WantName || labels["job"] != conformanceJob || labels["instance"] != conformanceInstance {
+		return false
+	}
+	for k, v := range c.WantLabels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ConformanceResult is the outcome of a conformance case for one ingestion mode.
+type ConformanceResult struct {
+	Case        string `json:"case"`
+	Description string `json:"description"`
+	Want        string `json:"want"`
+	// Got lists the series stored for the case, empty if it was dropped or rejected.
+	Got  []string `json:"got,omitempty"`
+	Pass bool     `json:"pass"`
+}
+
+// ConformanceReport holds the results of all cases by ingestion mode and the cases whose stored series
+// differ between the modes.
+type ConformanceReport struct {
+	Modes       []IngestionMode                       `json:"modes"`
+	Results     map[IngestionMode][]ConformanceResult `json:"results"`
+	Differences []string                              `json:"differences,omitempty"`
+}
+
+// Failed returns the number of failed cases over all modes.
+func (r *ConformanceReport) Failed() int {
+	failed := 0
+	for _, results := range r.Results {
+		for _, result := range results {
+			if !result.Pass {
+				failed++
+			}
+		}
+	}
+	return failed
+}
+
+// checkConformance queries the stored series of every case since start.
+func checkConformance(client *PrometheusClient, cases []conformanceCase, start time.Time) ([]ConformanceResult, error) {
+	results := make([]ConformanceResult, 0, len(cases))
+	for _, c := range cases {
+		stored, err := client.Series([]string{c.matcher()}, start.Add(-time.Minute), time.Now().Add(time.Minute))
+		if err != nil {
+			return nil, err
+		}
+		result := ConformanceResult{Case: c.ID, Description: c.Description, Want: c.want()}
+		for _, labels := range stored {
+			result.Got = append(result.Got, seriesKey(labels["__name__"], labels, nil))
+			result.Pass = result.Pass || c.matches(labels)
+		}
+		sort.Strings(result.Got)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func allPassed(results []ConformanceResult) bool {
+	for _, result := range results {
+		if !result.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// conformanceDifferences returns a description of every case stored differently by the modes.
+func conformanceDifferences(report *ConformanceReport) []string {
+	var differences []string
+	if len(report.Modes) < 2 {
+		return nil
+	}
+	first := report.Results[report.Modes[0]]
+	for i, result := range first {
+		for _, mode := range report.Modes[1:] {
+			other := report.Results[mode]
+			if i >= len(other) {
+				continue
+			}
+			if strings.Join(result.Got, " ") != strings.Join(other[i].Got, " ") {
+				differences = append(differences, fmt.Sprintf("%s: %s stored %v, %s stored %v",
+					result.Case, report.Modes[0], result.Got, mode, other[i].Got))
+			}
+		}
+	}
+	return differences
+}
+
+// runConformance sends every conformance case through the collector to Prometheus once per mode
+// of the matrix, remote write and native OTLP by default, and checks the names of the stored series.
+// The report is logged and written to conformance.md and conformance.json in results/<name>/conformance.
+func runConformance(spec ScenarioSpec) (*ConformanceReport, error) {
+	modes := spec.Matrix.Modes
+	if len(modes) == 0 {
+		modes = []IngestionMode{ModeRemoteWrite, ModeOTLPNative}
+	}
+	resultDir := path.Join("results", spec.Name, "conformance")
+	resultsSummary := &ScenarioResults{}
+	resultsSummary.Init(resultDir)
+
+	report := &ConformanceReport{Results: map[IngestionMode][]ConformanceResult{}}
+	for _, mode := range modes {
+		if mode == ModeMock {
+			continue
+		}
+		cell := spec
+		cell.Matrix = MatrixSpec{}
+		cell.Mode = mode
+		cell.ValidateData = false
+		cell.Name = path.Join(spec.Name, "conformance", string(mode))
+		results, err := runConformanceMode(cell, resultsSummary)
+		if err != nil {
+			return nil, fmt.Errorf("conformance of %s: %w", mode, err)
+		}
+		report.Modes = append(report.Modes, mode)
+		report.Results[mode] = results
+	}
+	resultsSummary.Save()
+	report.Differences = conformanceDifferences(report)
+
+	var table strings.Builder
+	writeConformanceTable(&table, report)
+	log.Printf("Conformance results of %s:\n%s", spec.Name, table.String())
+	if err := os.WriteFile(path.Join(resultDir, "conformance.md"), []byte(table.String()), 0644); err != nil {
+		return report, err
+	}
+	return report, writeJSONFile(path.Join(resultDir, "conformance.json"), report)
+}
+
+// runConformanceMode sends every case in its own request and waits up to 30 seconds for all of them
+// to be stored.
+func runConformanceMode(spec ScenarioSpec, resultsSummary testbed.TestResultsSummary) ([]ConformanceResult, error) {
+	start := time.Now()
+	batches := make([]pmetric.Metrics, len(conformanceCases))
+	for i, c := range conformanceCases {
+		batches[i] = c.metrics(start)
+	}
+
+	cases := append(append([]conformanceCase(nil), conformanceCases...), targetInfoCase)
+	var results []ConformanceResult
+	err := sendAndCheck(spec, resultsSummary, batches, func(client *PrometheusClient) (bool, error) {
+		var err error
+		results, err = checkConformance(client, cases, start)
+		return err == nil && allPassed(results), err
+	})
+	return results, err
+}
+
+// sendAndCheck runs the processes of the cell, sends every batch in its own request and calls check
+// every second until it returns true, fails or 30 seconds passed. A batch rejected by Prometheus is
+// logged and left to check, which finds its series missing.
+func sendAndCheck(spec ScenarioSpec, resultsSummary testbed.TestResultsSummary, batches []pmetric.Metrics,
+	check func(client *PrometheusClient) (bool, error)) error {
+	scenario, err := NewScenarioFromSpec(spec, resultsSummary)
+	if err != nil {
+		return err
+	}
+	defer scenario.Stop()
+
+	scenario.StartBackend()
+	scenario.StartAgent()
+	scenario.StartPrometheus(spec.Prometheus.args()...)
+	if scenario.Failed() {
+		return errors.New(scenario.errorCause)
+	}
+
+	sender, ok := scenario.Sender.(testbed.MetricDataSender)
+	if !ok {
+		return errors.New("sender does not send metrics")
+	}
+	if err := sender.Start(); err != nil {
+		return err
+	}
+	for i, md := range batches {
+		if err := sender.ConsumeMetrics(context.Background(), md); err != nil {
+			log.Printf("Cannot send batch %d: %s", i, err.Error())
+		}
+	}
+
+	client := NewPrometheusClient(AddressLocalhost, scenario.promPort)
+	for deadline := time.Now().Add(30 * time.Second); ; {
+		var done bool
+		if done, err = check(client); err != nil || done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	scenario.StopAgent()
+	scenario.StopPrometheus()
+	return err
+}
+
+// writeConformanceTable writes one markdown table row per case with the outcome of every mode,
+// followed by the differences between the modes.
+func writeConformanceTable(w io.Writer, report *ConformanceReport) {
+	fmt.Fprint(w, "Case|Want")
+	for _, mode := range report.Modes {
+		fmt.Fprintf(w, "|%s", mode)
+	}
+	fmt.Fprint(w, "\n----|----")
+	for range report.Modes {
+		fmt.Fprint(w, "|----")
+	}
+	fmt.Fprintln(w)
+
+	if len(report.Modes) == 0 {
+		return
+	}
+	for i, result := range report.Results[report.Modes[0]] {
+		fmt.Fprintf(w, "%s|`%s`", result.Case, result.Want)
+		for _, mode := range report.Modes {
+			r := report.Results[mode][i]
+			switch {
+			case r.Pass:
+				fmt.Fprint(w, "|PASS")
+			case len(r.Got) == 0:
+				fmt.Fprint(w, "|FAIL: not stored")
+			default:
+				fmt.Fprintf(w, "|FAIL: `%s`", strings.Join(r.Got, "`, `"))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(report.Differences) > 0 {
+		fmt.Fprint(w, "\nDifferences between the modes:\n\n")
+		for _, difference := range report.Differences {
+			fmt.Fprintf(w, "- %s\n", difference)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// TestConformanceCases checks that the expectations of the conformance cases agree with the translation
+// predicted by promMetricName and promLabelName.
+func TestConformanceCases(t *testing.T) {
+	ids := map[string]bool{}
+	for _, c := range conformanceCases {
+		t.Run(c.ID, func(t *testing.T) {
+			if ids[c.ID] {
+				t.Fatalf("duplicate case id %s", c.ID)
+			}
+			ids[c.ID] = true
+
+			md := c.metrics(time.Now())
+			rm := md.ResourceMetrics().At(0)
+			if name, _ := rm.Resource().Attributes().Get("service.name"); name.Str() != "conformance" {
+				t.Errorf("got resource %v", rm.Resource().Attributes().AsRaw())
+			}
+			metric := rm.ScopeMetrics().At(0).Metrics().At(0)
+
+			name := promMetricName(metric)
+			if metric.Type() == pmetric.MetricTypeHistogram {
+				name += "_bucket"
+			}
+			if name != c.WantName {
+				t.Errorf("got name %s, want %s", name, c.WantName)
+			}
+			for key := range c.Attributes {
+				if _, ok := c.WantLabels[promLabelName(key)]; !ok {
+					t.Errorf("attribute %s is label %s, not in %v", key, promLabelName(key), c.WantLabels)
+				}
+			}
+			if promLabelName(conformanceCaseAttribute) != conformanceCaseLabel {
+				t.Errorf("got case label %s", promLabelName(conformanceCaseAttribute))
+			}
+
+			labels := map[string]string{"__name__": c.WantName, "job": conformanceJob, "instance": conformanceInstance}
+			for k, v := range c.WantLabels {
+				labels[k] = v
+			}
+			if !c.matches(labels) {
+				t.Errorf("expected series %v does not match", labels)
+			}
+			labels["__name__"] = c.Name
+			if c.matches(labels) {
+				t.Errorf("untranslated series %v matches", labels)
+			}
+		})
+	}
+}
+
+func TestConformanceReport(t *testing.T) {
+	passed := ConformanceResult{Case: "dots", Want: `http_server_active_requests{job="bench/conformance"}`, Pass: true,
+		Got: []string{`http_server_active_requests{job="bench/conformance"}`}}
+	failed := ConformanceResult{Case: "dots", Want: passed.Want, Got: []string{`http_server_active_requests_total{job="bench/conformance"}`}}
+	report := &ConformanceReport{
+		Modes: []IngestionMode{ModeRemoteWrite, ModeOTLPNative},
+		Results: map[IngestionMode][]ConformanceResult{
+			ModeRemoteWrite: {passed},
+			ModeOTLPNative:  {failed},
+		},
+	}
+	report.Differences = conformanceDifferences(report)
+
+	if report.Failed() != 1 {
+		t.Errorf("got %d failed cases", report.Failed())
+	}
+	if len(report.Differences) != 1 || !strings.HasPrefix(report.Differences[0], "dots: remote-write stored") {
+		t.Errorf("got differences %v", report.Differences)
+	}
+
+	var table strings.Builder
+	writeConformanceTable(&table, report)
+	if !strings.Contains(table.String(), "|PASS|FAIL: `http_server_active_requests_total") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+}
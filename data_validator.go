@@ -0,0 +1,449 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// validationIgnoredLabels are added by the ingestion path and not part of the generated data: job and
+// instance come from the resource, scenario is the remote write external label.
+var validationIgnoredLabels = []string{"job", "instance", "scenario"}
+
+// maxReportedSeries limits the number of series listed by name in a DataValidationReport.
+const maxReportedSeries = 100
+
+// recordingDataProvider wraps a DataProvider and keeps a copy of every generated metrics batch, so
+// the data can be compared with what Prometheus stored. Traces and logs are passed through.
+type recordingDataProvider struct {
+	testbed.DataProvider
+
+	mutex   sync.Mutex
+	metrics []pmetric.Metrics
+}
+
+func newRecordingDataProvider(provider testbed.DataProvider) *recordingDataProvider {
+	return &recordingDataProvider{DataProvider: provider}
+}
+
+func (dp *recordingDataProvider) GenerateMetrics() (pmetric.Metrics, bool) {
+	md, done := dp.DataProvider.GenerateMetrics()
+
+	recorded := pmetric.NewMetrics()
+	md.CopyTo(recorded)
+	dp.mutex.Lock()
+	dp.metrics = append(dp.metrics, recorded)
+	dp.mutex.Unlock()
+
+	return md, done
+}
+
+// Metrics returns all batches generated so far.
+func (dp *recordingDataProvider) Metrics() []pmetric.Metrics {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	return append([]pmetric.Metrics(nil), dp.metrics...)
+}
+
+// expectedSeries is a series Prometheus should store, with its samples by millisecond timestamp.
+type expectedSeries struct {
+	name   string
+	labels map[string]string
+	// samples maps timestamps to values, NaN for native histogram samples whose value is not compared.
+	samples map[int64]float64
+	// delta is set for series of delta sums and histograms, whose samples are accumulated to the
+	// cumulative values Prometheus stores after conversion.
+	delta bool
+}
+
+// expectedData indexes the expected series by metric name and series key.
+type expectedData struct {
+	series map[string]map[string]*expectedSeries
+	// untranslatable counts the data points whose metric name is empty after translation.
+	untranslatable int
+}
+
+func newExpectedData(batches []pmetric.Metrics) *expectedData {
+	data := &expectedData{series: map[string]map[string]*expectedSeries{}}
+	for _, md := range batches {
+		rms := md.ResourceMetrics()
+		for i := 0; i < rms.Len(); i++ {
+			sms := rms.At(i).ScopeMetrics()
+			for j := 0; j < sms.Len(); j++ {
+				metrics := sms.At(j).Metrics()
+				for k := 0; k < metrics.Len(); k++ {
+					data.addMetric(metrics.At(k))
+				}
+			}
+		}
+	}
+	data.accumulateDeltas()
+	return data
+}
+
+// accumulateDeltas replaces the samples of the delta series by their running sum in timestamp order.
+func (data *expectedData) accumulateDeltas() {
+	for _, byKey := range data.series {
+		for _, series := range byKey {
+			if !series.delta {
+				continue
+			}
+			timestamps := make([]int64, 0, len(series.samples))
+			for ts := range series.samples {
+				timestamps = append(timestamps, ts)
+			}
+			sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+			var total float64
+			for _, ts := range timestamps {
+				total += series.samples[ts]
+				series.samples[ts] = total
+			}
+		}
+	}
+}
+
+// isDelta returns true for sums and histograms of delta temporality.
+func isDelta(metric pmetric.Metric) bool {
+	switch metric.Type() {
+	case pmetric.MetricTypeSum:
+		return metric.Sum().AggregationTemporality() == pmetric.AggregationTemporalityDelta
+	case pmetric.MetricTypeHistogram:
+		return metric.Histogram().AggregationTemporality() == pmetric.AggregationTemporalityDelta
+	case pmetric.MetricTypeExponentialHistogram:
+		return metric.ExponentialHistogram().AggregationTemporality() == pmetric.AggregationTemporalityDelta
+	}
+	return false
+}
+
+func (data *expectedData) addMetric(metric pmetric.Metric) {
+	name := promMetricName(metric)
+	delta := isDelta(metric)
+	addSample := func(name string, attrs pcommon.Map, extra map[string]string, ts pcommon.Timestamp, value float64) {
+		if series := data.addSample(name, attrs, extra, ts, value); series != nil && delta {
+			series.delta = true
+		}
+	}
+
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSum:
+		var dps pmetric.NumberDataPointSlice
+		if metric.Type() == pmetric.MetricTypeGauge {
+			dps = metric.Gauge().DataPoints()
+		} else {
+			dps = metric.Sum().DataPoints()
+		}
+		for i := 0; i < dps.Len(); i++ {
+			dp := dps.At(i)
+			value := dp.DoubleValue()
+			if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+				value = float64(dp.IntValue())
+			}
+			addSample(name, dp.Attributes(), nil, dp.Timestamp(), value)
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dp := dps.At(i)
+			// One cumulative bucket per bound plus the +Inf bucket holding the count.
+			var cumulative uint64
+			for b := 0; b < dp.ExplicitBounds().Len(); b++ {
+				if b < dp.BucketCounts().Len() {
+					cumulative += dp.BucketCounts().At(b)
+				}
+				le := formatBound(dp.ExplicitBounds().At(b))
+				addSample(name+"_bucket", dp.Attributes(), map[string]string{"le": le}, dp.Timestamp(), float64(cumulative))
+			}
+			addSample(name+"_bucket", dp.Attributes(), map[string]string{"le": "+Inf"}, dp.Timestamp(), float64(dp.Count()))
+			if dp.HasSum() {
+				addSample(name+"_sum", dp.Attributes(), nil, dp.Timestamp(), dp.Sum())
+			}
+			addSample(name+"_count", dp.Attributes(), nil, dp.Timestamp(), float64(dp.Count()))
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dp := dps.At(i)
+			addSample(name, dp.Attributes(), nil, dp.Timestamp(), math.NaN())
+		}
+	case pmetric.MetricTypeSummary:
+		dps := metric.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dp := dps.At(i)
+			for q := 0; q < dp.QuantileValues().Len(); q++ {
+				qv := dp.QuantileValues().At(q)
+				addSample(name, dp.Attributes(), map[string]string{"quantile": formatBound(qv.Quantile())}, dp.Timestamp(), qv.Value())
+			}
+			addSample(name+"_sum", dp.Attributes(), nil, dp.Timestamp(), dp.Sum())
+			addSample(name+"_count", dp.Attributes(), nil, dp.Timestamp(), float64(dp.Count()))
+		}
+	}
+}
+
+// addSample adds a sample to the expected series and returns the series, nil if the name is empty.
+func (data *expectedData) addSample(name string, attrs pcommon.Map, extra map[string]string, ts pcommon.Timestamp, value float64) *expectedSeries {
+	if name == "" {
+		data.untranslatable++
+		return nil
+	}
+
+	labels := make(map[string]string, attrs.Len()+len(extra))
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		labels[promLabelName(k)] = v.AsString()
+		return true
+	})
+	for k, v := range extra {
+		labels[k] = v
+	}
+
+	byKey, ok := data.series[name]
+	if !ok {
+		byKey = map[string]*expectedSeries{}
+		data.series[name] = byKey
+	}
+	key := seriesKey(name, labels, nil)
+	series, ok := byKey[key]
+	if !ok {
+		series = &expectedSeries{name: name, labels: labels, samples: map[int64]float64{}}
+		byKey[key] = series
+	}
+	series.samples[ts.AsTime().UnixMilli()] = value
+	return series
+}
+
+// seriesKey returns the series in PromQL notation with sorted labels, leaving out the ignored ones.
+func seriesKey(name string, labels map[string]string, ignored []string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		if k == "__name__" || containsToken(ignored, k) {
+			continue
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, k := range names {
+		pairs[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// DataValidationReport compares the generated data with the data stored by Prometheus.
+type DataValidationReport struct {
+	ExpectedSeries int `json:"expected_series"`
+	FoundSeries    int `json:"found_series"`
+	// MissingSeries and ExtraSeries list at most maxReportedSeries series each.
+	MissingSeriesCount int      `json:"missing_series_count"`
+	MissingSeries      []string `json:"missing_series,omitempty"`
+	ExtraSeriesCount   int      `json:"extra_series_count"`
+	ExtraSeries        []string `json:"extra_series,omitempty"`
+
+	ExpectedSamples int `json:"expected_samples"`
+	MatchedSamples  int `json:"matched_samples"`
+	MissingSamples  int `json:"missing_samples"`
+	// WrongValueSamples were stored at the expected timestamp with a different value.
+	WrongValueSamples int `json:"wrong_value_samples"`
+	// ExtraSamples were stored at timestamps that were not generated.
+	ExtraSamples int `json:"extra_samples"`
+
+	// UntranslatablePoints were generated without a usable metric name and cannot be stored.
+	UntranslatablePoints int `json:"untranslatable_points"`
+
+	// DeltaSeries are the expected series of delta sums and histograms, expected with their cumulative
+	// values. MissingDeltaSeries were dropped or rejected on the way, see the collector exporter counters.
+	DeltaSeries        int `json:"delta_series,omitempty"`
+	MissingDeltaSeries int `json:"missing_delta_series,omitempty"`
+}
+
+// OK returns true if Prometheus stored exactly the generated data.
+func (r *DataValidationReport) OK() bool {
+	return r.MissingSeriesCount == 0 && r.ExtraSeriesCount == 0 && r.MissingSamples == 0 &&
+		r.WrongValueSamples == 0 && r.ExtraSamples == 0 && r.UntranslatablePoints == 0
+}
+
+func (r *DataValidationReport) String() string {
+	s := fmt.Sprintf("series expected:%d found:%d missing:%d extra:%d, samples expected:%d matched:%d missing:%d wrong value:%d extra:%d, untranslatable points:%d",
+		r.ExpectedSeries, r.FoundSeries, r.MissingSeriesCount, r.ExtraSeriesCount,
+		r.ExpectedSamples, r.MatchedSamples, r.MissingSamples, r.WrongValueSamples, r.ExtraSamples,
+		r.UntranslatablePoints)
+	if r.DeltaSeries > 0 {
+		s += fmt.Sprintf(", delta series expected:%d missing:%d", r.DeltaSeries, r.MissingDeltaSeries)
+	}
+	return s
+}
+
+// PrometheusDataValidator checks that the data generated by a recordingDataProvider landed in the
+// Prometheus TSDB with the right values and timestamps.
+type PrometheusDataValidator struct {
+	client   *PrometheusClient
+	recorder *recordingDataProvider
+	// ignoredLabels are not compared, see validationIgnoredLabels.
+	ignoredLabels []string
+	// settleTimeout bounds the time waited for in-flight samples to be stored.
+	settleTimeout time.Duration
+}
+
+// NewPrometheusDataValidator creates a validator comparing the data recorded by recorder with client's Prometheus.
+func NewPrometheusDataValidator(client *PrometheusClient, recorder *recordingDataProvider) *PrometheusDataValidator {
+	return &PrometheusDataValidator{
+		client:        client,
+		recorder:      recorder,
+		ignoredLabels: validationIgnoredLabels,
+		settleTimeout: 15 * time.Second,
+	}
+}
+
+// Validate compares the data generated since start with Prometheus. Samples still in flight when the
+// load stopped are waited for as long as the number of missing samples keeps decreasing.
+func (v *PrometheusDataValidator) Validate(start time.Time) (*DataValidationReport, error) {
+	expected := newExpectedData(v.recorder.Metrics())
+
+	deadline := time.Now().Add(v.settleTimeout)
+	report, err := v.compare(expected, start, time.Now())
+	for err == nil && report.MissingSamples > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+		var next *DataValidationReport
+		if next, err = v.compare(expected, start, time.Now()); err != nil {
+			break
+		}
+		if next.MissingSamples >= report.MissingSamples {
+			report = next
+			break
+		}
+		report = next
+	}
+	return report, err
+}
+
+func (v *PrometheusDataValidator) compare(expected *expectedData, start, end time.Time) (*DataValidationReport, error) {
+	report := &DataValidationReport{UntranslatablePoints: expected.untranslatable}
+
+	names := make([]string, 0, len(expected.series))
+	for name := range expected.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Range covering every sample since start, with a margin for clock rounding.
+	window := end.Sub(start).Round(time.Second) + time.Minute
+	for _, name := range names {
+		byKey := expected.series[name]
+		selector := fmt.Sprintf("{__name__=%q}", name)
+
+		storedSeries, err := v.client.Series([]string{selector}, start.Add(-time.Minute), end)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := v.client.Query(fmt.Sprintf("%s[%ds]", selector, int64(window.Seconds())), end)
+		if err != nil {
+			return nil, err
+		}
+		samplesByKey := make(map[string][]promSample, len(stored))
+		for _, series := range stored {
+			samplesByKey[seriesKey(name, series.Labels, v.ignoredLabels)] = series.Samples
+		}
+
+		found := make(map[string]bool, len(storedSeries))
+		for _, labels := range storedSeries {
+			key := seriesKey(name, labels, v.ignoredLabels)
+			if _, ok := byKey[key]; !ok {
+				report.addExtraSeries(key)
+				continue
+			}
+			found[key] = true
+		}
+
+		for key, series := range byKey {
+			report.ExpectedSeries++
+			report.ExpectedSamples += len(series.samples)
+			if series.delta {
+				report.DeltaSeries++
+			}
+			if !found[key] {
+				report.addMissingSeries(key)
+				report.MissingSamples += len(series.samples)
+				if series.delta {
+					report.MissingDeltaSeries++
+				}
+				continue
+			}
+			report.FoundSeries++
+			report.compareSamples(series.samples, samplesByKey[key])
+		}
+	}
+	sort.Strings(report.MissingSeries)
+	sort.Strings(report.ExtraSeries)
+	return report, nil
+}
+
+func (r *DataValidationReport) compareSamples(expected map[int64]float64, stored []promSample) {
+	seen := make(map[int64]bool, len(stored))
+	for _, sample := range stored {
+		value, ok := expected[sample.Timestamp]
+		if !ok {
+			r.ExtraSamples++
+			continue
+		}
+		seen[sample.Timestamp] = true
+		if sample.Histogram || math.IsNaN(value) || floatEquals(value, sample.Value) {
+			r.MatchedSamples++
+		} else {
+			r.WrongValueSamples++
+		}
+	}
+	r.MissingSamples += len(expected) - len(seen)
+}
+
+func (r *DataValidationReport) addMissingSeries(key string) {
+	r.MissingSeriesCount++
+	if len(r.MissingSeries) < maxReportedSeries {
+		r.MissingSeries = append(r.MissingSeries, key)
+	}
+}
+
+func (r *DataValidationReport) addExtraSeries(key string) {
+	r.ExtraSeriesCount++
+	if len(r.ExtraSeries) < maxReportedSeries {
+		r.ExtraSeries = append(r.ExtraSeries, key)
+	}
+}
+
+func floatEquals(a, b float64) bool {
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// writeJSONFile writes v as indented JSON to fileName.
+func writeJSONFile(fileName string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(data, '\n'), 0644)
+}
+
+// logValidationReport logs the summary of the report and the first missing and extra series.
+func logValidationReport(report *DataValidationReport) {
+	log.Printf("Prometheus data validation: %s", report)
+	if report.DeltaSeries > 0 && report.MissingDeltaSeries == report.DeltaSeries {
+		log.Printf("No delta series stored, delta sums and histograms were dropped or rejected")
+	}
+	for _, key := range report.MissingSeries {
+		log.Printf("Missing series: %s", key)
+	}
+	for _, key := range report.ExtraSeries {
+		log.Printf("Extra series: %s", key)
+	}
+}
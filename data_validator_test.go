@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestPrometheusDataValidator(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "loadgen")
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("requests")
+	metric.SetEmptySum().SetIsMonotonic(true)
+	for i, attr := range []string{"a", "a", "a", "b"} {
+		dp := metric.Sum().DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("item.index", attr)
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Duration(i) * time.Second)))
+		dp.SetIntValue(int64(i))
+	}
+
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(start.Add(d).UnixMilli())/1000, 'f', 3, 64)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/series":
+			fmt.Fprint(w, `{"status":"success","data":[
+				{"__name__":"requests_total","item_index":"a","job":"loadgen"},
+				{"__name__":"requests_total","item_index":"c","job":"loadgen"}]}`)
+		case "/api/v1/query":
+			// Sample 0 matches, sample 1 has a wrong value, sample 2 is missing, one sample is extra.
+			fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"__name__":"requests_total","item_index":"a","job":"loadgen"},
+				 "values":[[%s,"0"],[%s,"5"],[%s,"9"]]}]}}`, ms(0), ms(time.Second), ms(10*time.Second))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	recorder := newRecordingDataProvider(nil)
+	recorder.metrics = []pmetric.Metrics{md}
+	validator := NewPrometheusDataValidator(NewPrometheusClient("127.0.0.1", portNum), recorder)
+	validator.settleTimeout = 0
+
+	report, err := validator.Validate(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() {
+		t.Fatal("expected mismatches")
+	}
+
+	want := DataValidationReport{
+		ExpectedSeries:     2,
+		FoundSeries:        1,
+		MissingSeriesCount: 1,
+		MissingSeries:      []string{`requests_total{item_index="b"}`},
+		ExtraSeriesCount:   1,
+		ExtraSeries:        []string{`requests_total{item_index="c"}`},
+		ExpectedSamples:    4,
+		MatchedSamples:     1,
+		MissingSamples:     2,
+		WrongValueSamples:  1,
+		ExtraSamples:       1,
+	}
+	if fmt.Sprint(*report) != fmt.Sprint(want) {
+		t.Errorf("got report %+v, want %+v", *report, want)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exemplarTypes are the metric types of the histogram provider carrying exemplars.
+var exemplarTypes = []string{histogramTypeExplicit, histogramTypeExponential, histogramTypeSum}
+
+// ExemplarStats compares the exemplars attached to the data points of a metric type with those
+// Prometheus stored.
+type ExemplarStats struct {
+	Type string `json:"type"`
+	Sent uint64 `json:"sent"`
+	// Stored counts the exemplars returned by /api/v1/query_exemplars, WithTraceID those of them
+	// still carrying a valid trace_id and span_id label.
+	Stored      uint64 `json:"stored"`
+	WithTraceID uint64 `json:"with_trace_id"`
+}
+
+// Ratio returns the share of sent exemplars stored with their trace id.
+func (s ExemplarStats) Ratio() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.WithTraceID) / float64(s.Sent)
+}
+
+func (s ExemplarStats) String() string {
+	return fmt.Sprintf("%s sent:%d stored:%d with trace id:%d (%.1f%%)", s.Type, s.Sent, s.Stored, s.WithTraceID, 100*s.Ratio())
+}
+
+// ExemplarReport holds the exemplar stats of every metric type carrying exemplars.
+type ExemplarReport struct {
+	Types []ExemplarStats `json:"types"`
+}
+
+func (r *ExemplarReport) String() string {
+	stats := make([]string, len(r.Types))
+	for i, s := range r.Types {
+		stats[i] = s.String()
+	}
+	return strings.Join(stats, ", ")
+}
+
+// Stored returns the number of exemplars stored over all types.
+func (r *ExemplarReport) Stored() uint64 {
+	var stored uint64
+	for _, s := range r.Types {
+		stored += s.Stored
+	}
+	return stored
+}
+
+// exemplarType returns the metric type of the histogram provider a stored series belongs to, empty
+// for other series. The names are load_generator_<type> followed by the unit and type suffixes.
+func exemplarType(name string) string {
+	for _, typ := range exemplarTypes {
+		if strings.HasPrefix(name, "load_generator_"+typ+"_") {
+			return typ
+		}
+	}
+	return ""
+}
+
+// validTraceContext returns true if the exemplar labels hold a non-zero trace id and span id.
+func validTraceContext(labels map[string]string) bool {
+	traceID, err := hex.DecodeString(labels["trace_id"])
+	if err != nil || len(traceID) != 16 || strings.Trim(labels["trace_id"], "0") == "" {
+		return false
+	}
+	spanID, err := hex.DecodeString(labels["span_id"])
+	return err == nil && len(spanID) == 8
+}
+
+// newExemplarReport counts the stored exemplars by metric type and compares them with sent.
+func newExemplarReport(sent map[string]uint64, stored []promExemplarSeries) *ExemplarReport {
+	byType := map[string]*ExemplarStats{}
+	for _, typ := range exemplarTypes {
+		if sent[typ] > 0 {
+			byType[typ] = &ExemplarStats{Type: typ, Sent: sent[typ]}
+		}
+	}
+	for _, series := range stored {
+		typ := exemplarType(series.Labels["__name__"])
+		if typ == "" {
+			continue
+		}
+		stats, ok := byType[typ]
+		if !ok {
+			stats = &ExemplarStats{Type: typ}
+			byType[typ] = stats
+		}
+		for _, exemplar := range series.Exemplars {
+			stats.Stored++
+			if validTraceContext(exemplar.Labels) {
+				stats.WithTraceID++
+			}
+		}
+	}
+
+	report := &ExemplarReport{}
+	for _, stats := range byType {
+		report.Types = append(report.Types, *stats)
+	}
+	sort.Slice(report.Types, func(i, j int) bool {
+		return report.Types[i].Type < report.Types[j].Type
+	})
+	return report
+}
+
+// validateExemplars queries the exemplars of the histogram provider metrics stored since start.
+func validateExemplars(client *PrometheusClient, sent map[string]uint64, start time.Time) (*ExemplarReport, error) {
+	stored, err := client.QueryExemplars(`{__name__=~"load_generator_.+"}`, start.Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	return newExemplarReport(sent, stored), nil
+}
+
+// logExemplarReport logs the exemplar stats, with a warning for types none of whose exemplars survived.
+func logExemplarReport(report *ExemplarReport) {
+	for _, stats := range report.Types {
+		log.Printf("Exemplars %s", stats)
+		if stats.Sent > 0 && stats.WithTraceID == 0 {
+			log.Printf("No exemplar of %s reached Prometheus with its trace id", stats.Type)
+		}
+	}
+}
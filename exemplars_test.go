@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+)
+
+func TestHistogramDataProviderExemplars(t *testing.T) {
+	spec := DefaultHistogramSpec()
+	spec.Types = []string{histogramTypeExplicit, histogramTypeExponential, histogramTypeSummary, histogramTypeSum}
+	spec.Exemplars = true
+	provider := NewHistogramDataProvider(testbed.LoadOptions{ItemsPerBatch: 8}, spec).(*histogramDataProvider)
+
+	provider.GenerateMetrics()
+	md, _ := provider.GenerateMetrics()
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+
+	exemplars := metrics.At(0).Histogram().DataPoints().At(1).Exemplars()
+	if exemplars.Len() != 1 {
+		t.Fatalf("got %d histogram exemplars", exemplars.Len())
+	}
+	exemplar := exemplars.At(0)
+	if exemplar.TraceID().String() != "00000000000000020000000000000002" || exemplar.SpanID().String() != "0000000000000002" {
+		t.Errorf("got trace id %s span id %s, want batch 2 item 1", exemplar.TraceID(), exemplar.SpanID())
+	}
+	if exemplar.DoubleValue() != 1 || exemplar.Timestamp() != metrics.At(0).Histogram().DataPoints().At(1).Timestamp() {
+		t.Errorf("got exemplar value %g at %s", exemplar.DoubleValue(), exemplar.Timestamp())
+	}
+	if metrics.At(1).ExponentialHistogram().DataPoints().At(0).Exemplars().Len() != 1 || metrics.At(3).Sum().DataPoints().At(0).Exemplars().Len() != 1 {
+		t.Errorf("exponential histogram or sum without exemplar")
+	}
+
+	sent := provider.Exemplars()
+	if sent[histogramTypeExplicit] != 4 || sent[histogramTypeExponential] != 4 || sent[histogramTypeSum] != 4 || sent[histogramTypeSummary] != 0 {
+		t.Errorf("got exemplars %v", sent)
+	}
+}
+
+func TestValidateExemplars(t *testing.T) {
+	start := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_exemplars" {
+			http.NotFound(w, r)
+			return
+		}
+		if query := r.URL.Query().Get("query"); query != `{__name__=~"load_generator_.+"}` {
+			t.Errorf("got query %s", query)
+		}
+		ts := float64(start.UnixMilli()) / 1000
+		fmt.Fprintf(w, `{"status":"success","data":[
+			{"seriesLabels":{"__name__":"load_generator_histogram_seconds_bucket","le":"1"},
+			 "exemplars":[
+				{"labels":{"trace_id":"00000000000000010000000000000001","span_id":"0000000000000001"},"value":"1","timestamp":%[1]f},
+				{"labels":{"trace_id":"00000000000000020000000000000001","span_id":"0000000000000001"},"value":"1","timestamp":%[1]f}]},
+			{"seriesLabels":{"__name__":"load_generator_sum_seconds_total"},
+			 "exemplars":[{"labels":{},"value":"1","timestamp":%[1]f}]},
+			{"seriesLabels":{"__name__":"load_generator_summary_seconds_count"},
+			 "exemplars":[{"labels":{"trace_id":"00000000000000010000000000000001","span_id":"0000000000000001"},"value":"1","timestamp":%[1]f}]}]}`, ts)
+	}))
+	defer server.Close()
+
+	client := &PrometheusClient{baseURL: server.URL, httpClient: server.Client()}
+	report, err := validateExemplars(client, map[string]uint64{histogramTypeExplicit: 4, histogramTypeSum: 2, histogramTypeExponential: 2}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ExemplarStats{
+		{Type: histogramTypeExponential, Sent: 2},
+		{Type: histogramTypeExplicit, Sent: 4, Stored: 2, WithTraceID: 2},
+		{Type: histogramTypeSum, Sent: 2, Stored: 1},
+	}
+	if len(report.Types) != len(want) {
+		t.Fatalf("got %+v", report.Types)
+	}
+	for i, stats := range report.Types {
+		if stats != want[i] {
+			t.Errorf("got %+v, want %+v", stats, want[i])
+		}
+	}
+	if report.Stored() != 3 || report.Types[1].Ratio() != 0.5 {
+		t.Errorf("got %d stored, ratio %g", report.Stored(), report.Types[1].Ratio())
+	}
+}
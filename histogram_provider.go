@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Metric types generated by histogramDataProvider.
+const (
+	histogramTypeExplicit    = "histogram"
+	histogramTypeExponential = "exponential_histogram"
+	histogramTypeSummary     = "summary"
+	histogramTypeSum         = "sum"
+)
+
+// Temporalities of the sums and histograms generated by histogramDataProvider.
+const (
+	temporalityCumulative = "cumulative"
+	temporalityDelta      = "delta"
+)
+
+// HistogramSpec configures the metrics of histogramDataProvider.
+type HistogramSpec struct {
+	// Types are the generated metric types: histogram, exponential_histogram, summary and sum, all but
+	// sum by default.
+	Types []string `yaml:"types"`
+	// Temporality of the sums and histograms, cumulative or delta. Summaries are always cumulative.
+	Temporality string `yaml:"temporality"`
+	// Buckets is the number of explicit bounds of the histograms, the +Inf bucket not included.
+	Buckets int `yaml:"buckets"`
+	// ExponentialBuckets is the number of positive buckets of the exponential histograms, at Scale.
+	ExponentialBuckets int   `yaml:"exponential_buckets"`
+	Scale              int32 `yaml:"scale"`
+	// Quantiles are the quantiles of the summaries.
+	Quantiles []float64 `yaml:"quantiles"`
+	// Exemplars attaches an exemplar with trace and span id to every histogram, exponential histogram
+	// and sum data point.
+	Exemplars bool `yaml:"exemplars"`
+}
+
+// DefaultHistogramSpec returns the histogram settings used unless the scenario sets them.
+func DefaultHistogramSpec() HistogramSpec {
+	return HistogramSpec{
+		Types:              []string{histogramTypeExplicit, histogramTypeExponential, histogramTypeSummary},
+		Temporality:        temporalityCumulative,
+		Buckets:            10,
+		ExponentialBuckets: 20,
+		Scale:              3,
+		Quantiles:          []float64{0.5, 0.9, 0.99},
+	}
+}
+
+// Validate checks the settings.
+func (h HistogramSpec) Validate() error {
+	if len(h.Types) == 0 {
+		return fmt.Errorf("histograms need at least one type")
+	}
+	for _, t := range h.Types {
+		switch t {
+		case histogramTypeExplicit, histogramTypeExponential, histogramTypeSummary, histogramTypeSum:
+		default:
+			return fmt.Errorf("unknown histogram type %q, expecting %s, %s, %s or %s",
+				t, histogramTypeExplicit, histogramTypeExponential, histogramTypeSummary, histogramTypeSum)
+		}
+	}
+	if h.Temporality != temporalityCumulative && h.Temporality != temporalityDelta {
+		return fmt.Errorf("unknown histograms temporality %q, expecting %s or %s", h.Temporality, temporalityCumulative, temporalityDelta)
+	}
+	if h.Buckets <= 0 || h.ExponentialBuckets <= 0 {
+		return fmt.Errorf("histograms buckets and exponential_buckets must be greater than zero")
+	}
+	// The scales Prometheus native histograms support.
+	if h.Scale < -4 || h.Scale > 8 {
+		return fmt.Errorf("histograms scale %d out of range [-4, 8]", h.Scale)
+	}
+	for _, q := range h.Quantiles {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("histograms quantile %g out of range [0, 1]", q)
+		}
+	}
+	return nil
+}
+
+// histogramDataProvider is a testbed.DataProvider of explicit bucket histograms, exponential histograms,
+// summaries and monotonic sums, the metric types whose translation to Prometheus differs the most between
+// the ingestion paths. Every batch holds ItemsPerBatch data points spread over the types, one series per
+// item index and type, so the number of series stays constant. Each batch adds one observation to every
+// bucket and quantile and one to the sums, making the expected values depend on the batch number only.
+// With delta temporality the sums and histograms hold the observations of the batch only, starting at
+// the time of the previous batch.
+type histogramDataProvider struct {
+	options testbed.LoadOptions
+	spec    HistogramSpec
+
+	dataItemsGenerated *atomic.Uint64
+	startTime          pcommon.Timestamp
+
+	// mutex orders the batches of parallel load generator workers, the cumulative values of a series
+	// must increase with its timestamps.
+	mutex    sync.Mutex
+	batchNum uint64
+	// lastTime is the time of the previous batch, the start of the delta data points.
+	lastTime pcommon.Timestamp
+	// exemplars counts the exemplars attached by metric type.
+	exemplars map[string]uint64
+}
+
+// NewHistogramDataProvider creates a data provider of histograms and summaries of the given spec.
+func NewHistogramDataProvider(options testbed.LoadOptions, spec HistogramSpec) testbed.DataProvider {
+	return &histogramDataProvider{
+		options:   options,
+		spec:      spec,
+		startTime: pcommon.NewTimestampFromTime(time.Now()),
+		exemplars: map[string]uint64{},
+	}
+}
+
+// temporality returns the aggregation temporality of the sums and histograms.
+func (dp *histogramDataProvider) temporality() pmetric.AggregationTemporality {
+	if dp.spec.Temporality == temporalityDelta {
+		return pmetric.AggregationTemporalityDelta
+	}
+	return pmetric.AggregationTemporalityCumulative
+}
+
+// observations returns the observations per bucket of the current batch, the number of batches for
+// cumulative temporality and one for delta temporality.
+func (dp *histogramDataProvider) observations() uint64 {
+	if dp.spec.Temporality == temporalityDelta {
+		return 1
+	}
+	return dp.batchNum
+}
+
+// start returns the start time of the sums and histograms of the current batch.
+func (dp *histogramDataProvider) start() pcommon.Timestamp {
+	if dp.spec.Temporality == temporalityDelta && dp.lastTime != 0 {
+		return dp.lastTime
+	}
+	return dp.startTime
+}
+
+func (dp *histogramDataProvider) SetLoadGeneratorCounters(dataItemsGenerated *atomic.Uint64) {
+	dp.dataItemsGenerated = dataItemsGenerated
+}
+
+func (dp *histogramDataProvider) GenerateTraces() (ptrace.Traces, bool) {
+	return ptrace.NewTraces(), true
+}
+
+func (dp *histogramDataProvider) GenerateLogs() (plog.Logs, bool) {
+	return plog.NewLogs(), true
+}
+
+func (dp *histogramDataProvider) GenerateMetrics() (pmetric.Metrics, bool) {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	dp.batchNum++
+	now := pcommon.NewTimestampFromTime(time.Now())
+	start := dp.start()
+	dp.lastTime = now
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	for k, v := range dp.options.Attributes {
+		rm.Resource().Attributes().PutStr(k, v)
+	}
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+
+	for t, typ := range dp.spec.Types {
+		// Items are distributed round-robin, the first types get the remainder.
+		items := dp.options.ItemsPerBatch / len(dp.spec.Types)
+		if t < dp.options.ItemsPerBatch%len(dp.spec.Types) {
+			items++
+		}
+		if items == 0 {
+			continue
+		}
+
+		metric := metrics.AppendEmpty()
+		metric.SetName("load_generator_" + typ)
+		metric.SetDescription("Load Generator " + typ)
+		metric.SetUnit("s")
+		for i := 0; i < items; i++ {
+			var attrs pcommon.Map
+			switch typ {
+			case histogramTypeExplicit:
+				if i == 0 {
+					metric.SetEmptyHistogram().SetAggregationTemporality(dp.temporality())
+				}
+				dataPoint := metric.Histogram().DataPoints().AppendEmpty()
+				dp.fillHistogram(dataPoint)
+				dataPoint.SetStartTimestamp(start)
+				dataPoint.SetTimestamp(now)
+				dp.addExemplar(dataPoint.Exemplars(), typ, i, now)
+				attrs = dataPoint.Attributes()
+			case histogramTypeExponential:
+				if i == 0 {
+					metric.SetEmptyExponentialHistogram().SetAggregationTemporality(dp.temporality())
+				}
+				dataPoint := metric.ExponentialHistogram().DataPoints().AppendEmpty()
+				dp.fillExponentialHistogram(dataPoint)
+				dataPoint.SetStartTimestamp(start)
+				dataPoint.SetTimestamp(now)
+				dp.addExemplar(dataPoint.Exemplars(), typ, i, now)
+				attrs = dataPoint.Attributes()
+			case histogramTypeSummary:
+				if i == 0 {
+					metric.SetEmptySummary()
+				}
+				dataPoint := metric.Summary().DataPoints().AppendEmpty()
+				dp.fillSummary(dataPoint)
+				dataPoint.SetStartTimestamp(dp.startTime)
+				dataPoint.SetTimestamp(now)
+				attrs = dataPoint.Attributes()
+			case histogramTypeSum:
+				if i == 0 {
+					sum := metric.SetEmptySum()
+					sum.SetIsMonotonic(true)
+					sum.SetAggregationTemporality(dp.temporality())
+				}
+				dataPoint := metric.Sum().DataPoints().AppendEmpty()
+				dataPoint.SetDoubleValue(float64(dp.observations()))
+				dataPoint.SetStartTimestamp(start)
+				dataPoint.SetTimestamp(now)
+				dp.addExemplar(dataPoint.Exemplars(), typ, i, now)
+				attrs = dataPoint.Attributes()
+			}
+			attrs.PutStr("item_index", "item_"+strconv.Itoa(i))
+			if dp.dataItemsGenerated != nil {
+				dp.dataItemsGenerated.Add(1)
+			}
+		}
+	}
+	return md, false
+}
+
+// addExemplar attaches an exemplar of an observation of 1 at ts to a data point of the metric type typ,
+// if exemplars are enabled. Its trace id holds the batch number and item index, so that every
+// exemplar is unique.
+func (dp *histogramDataProvider) addExemplar(exemplars pmetric.ExemplarSlice, typ string, item int, ts pcommon.Timestamp) {
+	if !dp.spec.Exemplars {
+		return
+	}
+	var traceID [16]byte
+	binary.BigEndian.PutUint64(traceID[:8], dp.batchNum)
+	binary.BigEndian.PutUint64(traceID[8:], uint64(item)+1)
+	var spanID [8]byte
+	binary.BigEndian.PutUint64(spanID[:], uint64(item)+1)
+
+	exemplar := exemplars.AppendEmpty()
+	exemplar.SetTimestamp(ts)
+	exemplar.SetDoubleValue(1)
+	exemplar.SetTraceID(traceID)
+	exemplar.SetSpanID(spanID)
+	dp.exemplars[typ]++
+}
+
+// Exemplars returns the number of exemplars attached so far by metric type.
+func (dp *histogramDataProvider) Exemplars() map[string]uint64 {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	exemplars := make(map[string]uint64, len(dp.exemplars))
+	for typ, n := range dp.exemplars {
+		exemplars[typ] = n
+	}
+	return exemplars
+}
+
+// fillHistogram sets the bounds 1, 2, 4, ... and one observation per bucket and batch, at the upper
+// bound of the bucket or twice the last bound for the +Inf bucket.
+func (dp *histogramDataProvider) fillHistogram(dataPoint pmetric.HistogramDataPoint) {
+	n := dp.observations()
+	bounds := dataPoint.ExplicitBounds()
+	counts := dataPoint.BucketCounts()
+	var sum float64
+	for b := 0; b < dp.spec.Buckets; b++ {
+		bound := math.Ldexp(1, b)
+		bounds.Append(bound)
+		counts.Append(n)
+		sum += bound
+	}
+	counts.Append(n)
+	sum += 2 * math.Ldexp(1, dp.spec.Buckets-1)
+
+	dataPoint.SetCount(n * uint64(dp.spec.Buckets+1))
+	dataPoint.SetSum(sum * float64(n))
+}
+
+// fillExponentialHistogram sets one observation per positive bucket and one zero observation per
+// batch, the positive observations at the upper bound of their bucket.
+func (dp *histogramDataProvider) fillExponentialHistogram(dataPoint pmetric.ExponentialHistogramDataPoint) {
+	n := dp.observations()
+	base := math.Pow(2, math.Pow(2, -float64(dp.spec.Scale)))
+	dataPoint.SetScale(dp.spec.Scale)
+	dataPoint.SetZeroCount(n)
+	dataPoint.Positive().SetOffset(0)
+	var sum float64
+	for b := 0; b < dp.spec.ExponentialBuckets; b++ {
+		dataPoint.Positive().BucketCounts().Append(n)
+		sum += math.Pow(base, float64(b+1))
+	}
+
+	dataPoint.SetCount(n * uint64(dp.spec.ExponentialBuckets+1))
+	dataPoint.SetSum(sum * float64(n))
+}
+
+// fillSummary sets the quantiles to 100 times their rank, with 100 observations per batch.
+func (dp *histogramDataProvider) fillSummary(dataPoint pmetric.SummaryDataPoint) {
+	n := dp.batchNum
+	for _, q := range dp.spec.Quantiles {
+		qv := dataPoint.QuantileValues().AppendEmpty()
+		qv.SetQuantile(q)
+		qv.SetValue(100 * q)
+	}
+	dataPoint.SetCount(100 * n)
+	dataPoint.SetSum(5000 * float64(n))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestHistogramDataProvider(t *testing.T) {
+	spec := DefaultHistogramSpec()
+	spec.Buckets = 4
+	spec.ExponentialBuckets = 5
+	provider := NewHistogramDataProvider(testbed.LoadOptions{ItemsPerBatch: 10}, spec)
+	var generated atomic.Uint64
+	provider.SetLoadGeneratorCounters(&generated)
+
+	provider.GenerateMetrics()
+	md, done := provider.GenerateMetrics()
+	if done || generated.Load() != 20 || md.DataPointCount() != 10 {
+		t.Fatalf("got %d data points, %d generated", md.DataPointCount(), generated.Load())
+	}
+
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	if metrics.Len() != 3 {
+		t.Fatalf("got %d metrics, want one per type", metrics.Len())
+	}
+
+	histogram := metrics.At(0).Histogram().DataPoints()
+	if metrics.At(0).Type() != pmetric.MetricTypeHistogram || histogram.Len() != 4 {
+		t.Fatalf("got %s with %d points, want 4 histogram points", metrics.At(0).Type(), histogram.Len())
+	}
+	dp := histogram.At(0)
+	if dp.ExplicitBounds().Len() != 4 || dp.BucketCounts().Len() != 5 || dp.Count() != 10 || dp.Sum() != 2*(1+2+4+8+16) {
+		t.Errorf("got histogram count %d sum %g bounds %v", dp.Count(), dp.Sum(), dp.ExplicitBounds().AsRaw())
+	}
+
+	exponential := metrics.At(1).ExponentialHistogram().DataPoints().At(0)
+	if exponential.Scale() != 3 || exponential.Positive().BucketCounts().Len() != 5 || exponential.Count() != 12 || exponential.ZeroCount() != 2 {
+		t.Errorf("got exponential histogram scale %d count %d", exponential.Scale(), exponential.Count())
+	}
+
+	summary := metrics.At(2).Summary().DataPoints().At(0)
+	if summary.QuantileValues().Len() != 3 || summary.Count() != 200 || summary.QuantileValues().At(1).Value() != 90 {
+		t.Errorf("got summary count %d quantiles %d", summary.Count(), summary.QuantileValues().Len())
+	}
+
+	// The classic histograms are stored as one series per bucket plus _sum and _count.
+	expected := newExpectedData([]pmetric.Metrics{md})
+	if got := len(expected.series["load_generator_histogram_seconds_bucket"]); got != 4*5 {
+		t.Errorf("got %d bucket series, want 20", got)
+	}
+}
+
+func TestHistogramSpecValidate(t *testing.T) {
+	for name, modify := range map[string]func(*HistogramSpec){
+		"unknown type": func(h *HistogramSpec) { h.Types = []string{"gauge"} },
+		"no buckets":   func(h *HistogramSpec) { h.Buckets = 0 },
+		"large scale":  func(h *HistogramSpec) { h.Scale = 20 },
+		"quantile":     func(h *HistogramSpec) { h.Quantiles = []float64{1.5} },
+	} {
+		spec := DefaultHistogramSpec()
+		modify(&spec)
+		if spec.Validate() == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if err := DefaultHistogramSpec().Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHistogramDataProviderDelta(t *testing.T) {
+	spec := DefaultHistogramSpec()
+	spec.Types = []string{histogramTypeSum, histogramTypeExplicit}
+	spec.Temporality = temporalityDelta
+	spec.Buckets = 2
+	provider := NewHistogramDataProvider(testbed.LoadOptions{ItemsPerBatch: 2}, spec)
+
+	var batches []pmetric.Metrics
+	for i := 0; i < 3; i++ {
+		md, _ := provider.GenerateMetrics()
+		batches = append(batches, md)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	sum := batches[2].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum()
+	if sum.AggregationTemporality() != pmetric.AggregationTemporalityDelta || sum.DataPoints().At(0).DoubleValue() != 1 {
+		t.Errorf("got %s sum of %g, want delta of 1", sum.AggregationTemporality(), sum.DataPoints().At(0).DoubleValue())
+	}
+	if start, previous := sum.DataPoints().At(0).StartTimestamp(), batches[1].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints().At(0).Timestamp(); start != previous {
+		t.Errorf("delta starts at %s, want the previous batch time %s", start, previous)
+	}
+
+	// The validator expects the deltas accumulated to the values Prometheus stores after conversion.
+	expected := newExpectedData(batches)
+	for _, series := range expected.series["load_generator_sum_seconds_total"] {
+		if !series.delta || len(series.samples) != 3 {
+			t.Fatalf("got %+v", series)
+		}
+		var last float64
+		for _, value := range series.samples {
+			if value > last {
+				last = value
+			}
+		}
+		if last != 3 {
+			t.Errorf("got cumulative value %g after 3 batches", last)
+		}
+	}
+	for key, series := range expected.series["load_generator_histogram_seconds_count"] {
+		for _, value := range series.samples {
+			if value != 3 && value != 6 && value != 9 {
+				t.Errorf("%s: unexpected cumulative count %g", key, value)
+			}
+		}
+	}
+}
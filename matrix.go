@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MatrixSpec lists the values of each axis swept by the matrix command. The scenario runs once for every
+// combination, an empty axis keeps the value of the scenario.
+type MatrixSpec struct {
+	DataItemsPerSecond []int           `yaml:"data_items_per_second"`
+	ItemsPerBatch      []int           `yaml:"items_per_batch"`
+	Parallel           []int           `yaml:"parallel"`
+	Modes              []IngestionMode `yaml:"modes"`
+}
+
+// Validate checks the values of all axes.
+func (m *MatrixSpec) Validate() error {
+	for _, axis := range [][]int{m.DataItemsPerSecond, m.ItemsPerBatch, m.Parallel} {
+		for _, value := range axis {
+			if value <= 0 {
+				return errors.New("matrix data_items_per_second, items_per_batch and parallel must be greater than zero")
+			}
+		}
+	}
+	for _, mode := range m.Modes {
+		if err := mode.Set(string(mode)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cells returns one spec per combination of the axes, in the order modes, rates, batch sizes, parallelism.
+// Every cell writes its results to a sub-directory of the scenario results directory.
+func (m *MatrixSpec) cells(base ScenarioSpec) []ScenarioSpec {
+	modes := m.Modes
+	if len(modes) == 0 {
+		modes = []IngestionMode{base.Mode}
+	}
+	rates := orDefault(m.DataItemsPerSecond, base.Load.DataItemsPerSecond)
+	batchSizes := orDefault(m.ItemsPerBatch, base.Load.ItemsPerBatch)
+	parallels := orDefault(m.Parallel, base.Load.Parallel)
+
+	var cells []ScenarioSpec
+	for _, mode := range modes {
+		for _, rate := range rates {
+			for _, batchSize := range batchSizes {
+				for _, parallel := range parallels {
+					cell := base
+					cell.Matrix = MatrixSpec{}
+					cell.Mode = mode
+					cell.Load.DataItemsPerSecond = rate
+					cell.Load.ItemsPerBatch = batchSize
+					cell.Load.Parallel = parallel
+					cell.Name = path.Join(base.Name, fmt.Sprintf("%s-r%d-b%d-p%d", mode, rate, batchSize, parallel))
+					cells = append(cells, cell)
+				}
+			}
+		}
+	}
+	return cells
+}
+
+func orDefault(values []int, value int) []int {
+	if len(values) == 0 {
+		return []int{value}
+	}
+	return values
+}
+
+// runMatrix runs the scenario for every cell of its matrix, one after the other. The collector and
+// Prometheus are torn down and restarted between cells. The comparison table is logged and written
+// to results/<name>/matrix.md, next to the summary of all cells in TESTRESULTS.md.
+func runMatrix(spec ScenarioSpec) ([]*ScenarioResult, error) {
+	cells := spec.Matrix.cells(spec)
+	log.Printf("Running %d matrix cells of %s", len(cells), spec.Name)
+
+	resultsSummary := &ScenarioResults{}
+	resultsSummary.Init(path.Join("results", spec.Name))
+
+	results := make([]*ScenarioResult, 0, len(cells))
+	for i, cell := range cells {
+		log.Printf("Matrix cell %d/%d: %s", i+1, len(cells), cell.Name)
+		results = append(results, sendToPrometheus(cell, resultsSummary))
+	}
+	resultsSummary.Save()
+
+	var table strings.Builder
+	writeMatrixTable(&table, results)
+	log.Printf("Matrix results of %s:\n%s", spec.Name, table.String())
+
+	fileName := path.Join("results", spec.Name, "matrix.md")
+	if err := os.MkdirAll(path.Dir(fileName), os.ModePerm); err != nil {
+		return results, err
+	}
+	return results, os.WriteFile(fileName, []byte(table.String()), 0644)
+}
+
+// writeMatrixTable writes one markdown table row per cell. Backend Dropped counts the items the mock
+// backend did not receive, Prom Dropped the points the collector exporter to Prometheus failed to send
+// or to queue.
+func writeMatrixTable(w io.Writer, results []*ScenarioResult) {
+	fmt.Fprint(w,
+		"Mode        |  Rate/s|Batch|Parallel|Sent Items|Received Items|Backend Dropped|Prom Dropped|Throughput/s|Col CPU Avg%|Col CPU Max%|Col RAM Avg MiB|Col RAM Max MiB|Prom CPU Avg%|Prom CPU Max%|Prom RAM Avg MiB|Prom RAM Max MiB|Error\n"+
+			"------------|-------:|----:|-------:|---------:|-------------:|--------------:|-----------:|-----------:|-----------:|-----------:|--------------:|--------------:|------------:|------------:|---------------:|---------------:|-----\n")
+	for _, r := range results {
+		fmt.Fprintf(w, "%-12s|%8d|%5d|%8d|%10d|%14d|%15d|%12.0f|%12.1f|%12.1f|%12.1f|%15d|%15d|%13.1f|%13.1f|%16d|%16d|%s\n",
+			r.Mode,
+			r.Load.DataItemsPerSecond,
+			r.Load.ItemsPerBatch,
+			r.Load.Parallel,
+			r.ItemsSent,
+			r.ItemsReceived,
+			r.Dropped(),
+			r.PrometheusDropped(),
+			r.Throughput(),
+			r.Agent.CPUPercentAvg,
+			r.Agent.CPUPercentMax,
+			r.Agent.RAMMiBAvg,
+			r.Agent.RAMMiBMax,
+			r.Prometheus.CPUPercentAvg,
+			r.Prometheus.CPUPercentMax,
+			r.Prometheus.RAMMiBAvg,
+			r.Prometheus.RAMMiBMax,
+			r.ErrorCause,
+		)
+	}
+}
+
+// intList is a flag.Value holding a comma-separated list of integers.
+type intList []int
+
+func (l *intList) String() string {
+	values := make([]string, len(*l))
+	for i, value := range *l {
+		values[i] = strconv.Itoa(value)
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *intList) Set(value string) error {
+	*l = nil
+	for _, field := range strings.Split(value, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return err
+		}
+		*l = append(*l, i)
+	}
+	return nil
+}
+
+// modeList is a flag.Value holding a comma-separated list of ingestion modes.
+type modeList []IngestionMode
+
+func (l *modeList) String() string {
+	values := make([]string, len(*l))
+	for i, mode := range *l {
+		values[i] = string(mode)
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *modeList) Set(value string) error {
+	*l = nil
+	for _, field := range strings.Split(value, ",") {
+		var mode IngestionMode
+		if err := mode.Set(strings.TrimSpace(field)); err != nil {
+			return err
+		}
+		*l = append(*l, mode)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+)
+
+func TestMatrixCells(t *testing.T) {
+	spec, err := LoadScenarioSpec(filepath.Join("scenarios", "matrix.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cells := spec.Matrix.cells(spec)
+	if len(cells) != 3*3*2 {
+		t.Fatalf("expected 18 cells, got %d", len(cells))
+	}
+	first := cells[0]
+	if first.Name != "matrix/mock-r1000-b100-p1" || first.Mode != ModeMock || first.Load.ItemsPerBatch != 100 {
+		t.Errorf("unexpected first cell %+v", first)
+	}
+	last := cells[len(cells)-1]
+	if last.Mode != ModeOTLPNative || last.Load.DataItemsPerSecond != 20000 || last.Load.ItemsPerBatch != 1000 {
+		t.Errorf("unexpected last cell %+v", last)
+	}
+
+	// Without axes the matrix is the scenario itself.
+	spec.Matrix = MatrixSpec{}
+	if cells = spec.Matrix.cells(spec); len(cells) != 1 || cells[0].Load.DataItemsPerSecond != spec.Load.DataItemsPerSecond {
+		t.Errorf("unexpected cells %+v", cells)
+	}
+}
+
+func TestMatrixTableDropped(t *testing.T) {
+	results := []*ScenarioResult{{
+		Mode:          ModeRemoteWrite,
+		ItemsSent:     1000,
+		ItemsReceived: 990,
+		Agent:         &testbed.ResourceConsumption{},
+		Prometheus:    &testbed.ResourceConsumption{},
+		Exporters: []ExporterStats{
+			{Exporter: "otlphttp", SendFailedPoints: 10},
+			{Exporter: "prometheusremotewrite", SendFailedPoints: 30, EnqueueFailedPoints: 20},
+		},
+	}}
+
+	var table strings.Builder
+	writeMatrixTable(&table, results)
+	if !strings.Contains(table.String(), "|      1000|           990|             10|          50|") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+}
+
+func TestSendToPrometheusInvalidCell(t *testing.T) {
+	spec := DefaultScenarioSpec()
+	spec.Load.ItemsPerBatch = 0
+
+	result := sendToPrometheus(spec, &ScenarioResults{})
+	if !strings.Contains(result.ErrorCause, "items_per_batch") || result.Name != spec.Name || result.Mode != spec.Mode {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// The failed cell is a row of the matrix table.
+	var table strings.Builder
+	writeMatrixTable(&table, []*ScenarioResult{result})
+	if !strings.Contains(table.String(), result.ErrorCause) {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricSelector selects the series of a metric family having all the given labels. Histograms and
+// summaries are selected as their _count and _sum series.
+type metricSelector struct {
+	name   string
+	labels map[string]string
+}
+
+func (sel metricSelector) matches(labels map[string]string) bool {
+	for k, v := range sel.labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// prometheusSelfMetrics are the series of Prometheus' own /metrics telling where it spends effort
+// per ingestion path: head and WAL growth, request latency of the OTLP and remote write handlers and
+// rejected samples.
+var prometheusSelfMetrics = []metricSelector{
+	{name: "prometheus_tsdb_head_series"},
+	{name: "prometheus_tsdb_head_chunks"},
+	{name: "prometheus_tsdb_head_samples_appended_total"},
+	{name: "prometheus_tsdb_wal_storage_size_bytes"},
+	{name: "prometheus_tsdb_wal_fsync_duration_seconds"},
+	{name: "prometheus_tsdb_compactions_total"},
+	{name: "prometheus_tsdb_head_gc_duration_seconds"},
+	{name: "prometheus_tsdb_out_of_order_samples_total"},
+	{name: "prometheus_tsdb_head_out_of_order_samples_appended_total"},
+	{name: "prometheus_tsdb_too_old_samples_total"},
+	{name: "prometheus_http_request_duration_seconds", labels: map[string]string{"handler": "/api/v1/otlp/v1/metrics"}},
+	{name: "prometheus_http_request_duration_seconds", labels: map[string]string{"handler": "/api/v1/write"}},
+	{name: "prometheus_http_requests_total", labels: map[string]string{"handler": "/api/v1/otlp/v1/metrics"}},
+	{name: "prometheus_http_requests_total", labels: map[string]string{"handler": "/api/v1/write"}},
+	{name: "go_memstats_heap_inuse_bytes"},
+	{name: "go_goroutines"},
+}
+
+// ScrapedSample is the value of one series at one scrape.
+type ScrapedSample struct {
+	Time   time.Time
+	Name   string
+	Labels map[string]string
+	// Series is the series in PromQL notation.
+	Series string
+	Value  float64
+	// Counter is set for counters and the _count and _sum of histograms and summaries, which start
+	// again from zero when the scraped process restarts.
+	Counter bool
+}
+
+// MetricsScraper periodically scrapes a /metrics endpoint in the Prometheus text format and keeps the
+// samples of the selected series.
+type MetricsScraper struct {
+	// Descriptive name of the scraped process.
+	name       string
+	url        string
+	period     time.Duration
+	selectors  []metricSelector
+	httpClient *http.Client
+
+	startOnce  sync.Once
+	stopOnce   sync.Once
+	doneSignal chan struct{}
+	stopped    chan struct{}
+
+	mutex   sync.Mutex
+	samples []ScrapedSample
+
+	// scrapeMutex serializes the scrapes of run and ScrapeNow.
+	scrapeMutex sync.Mutex
+	// failures counts the failed scrapes, only the first one is logged. Guarded by scrapeMutex.
+	failures int
+}
+
+// NewMetricsScraper creates a scraper of url, keeping the series selected by selectors every period.
+func NewMetricsScraper(name string, url string, period time.Duration, selectors []metricSelector) *MetricsScraper {
+	return &MetricsScraper{
+		name:       name,
+		url:        url,
+		period:     period,
+		selectors:  selectors,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		doneSignal: make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Start starts scraping in the background.
+func (s *MetricsScraper) Start() {
+	s.startOnce.Do(func() {
+		go s.run()
+	})
+}
+
+// Stop scrapes a last time, so that the final values are known, and stops scraping.
+func (s *MetricsScraper) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.doneSignal)
+	})
+	// A scraper that was never started must not start after Stop.
+	s.startOnce.Do(func() {
+		close(s.stopped)
+	})
+	<-s.stopped
+}
+
+func (s *MetricsScraper) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+
+	s.scrapeOnce()
+	for {
+		select {
+		case <-ticker.C:
+			s.scrapeOnce()
+		case <-s.doneSignal:
+			s.scrapeOnce()
+			s.scrapeMutex.Lock()
+			if s.failures > 0 {
+				log.Printf("%d scrapes of %s %s failed", s.failures, s.name, s.url)
+			}
+			s.scrapeMutex.Unlock()
+			return
+		}
+	}
+}
+
+// ScrapeNow scrapes once in addition to the periodic scrapes, e.g. to keep the final values of a
+// process about to stop. It is safe to call while the scraper runs.
+func (s *MetricsScraper) ScrapeNow() {
+	s.scrapeOnce()
+}
+
+func (s *MetricsScraper) scrapeOnce() {
+	s.scrapeMutex.Lock()
+	defer s.scrapeMutex.Unlock()
+
+	now := time.Now()
+	samples, err := s.scrape(now)
+	if err != nil {
+		if s.failures == 0 {
+			log.Printf("Cannot scrape %s metrics: %s", s.name, err.Error())
+		}
+		s.failures++
+		return
+	}
+
+	s.mutex.Lock()
+	s.samples = append(s.samples, samples...)
+	s.mutex.Unlock()
+}
+
+func (s *MetricsScraper) scrape(now time.Time) ([]ScrapedSample, error) {
+	resp, err := s.httpClient.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", s.url, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return selectSamples(families, s.selectors, now), nil
+}
+
+// selectSamples returns the samples of the selected series, sorted by series.
+func selectSamples(families map[string]*dto.MetricFamily, selectors []metricSelector, now time.Time) []ScrapedSample {
+	var samples []ScrapedSample
+	add := func(name string, labels map[string]string, value float64, counter bool) {
+		samples = append(samples, ScrapedSample{Time: now, Name: name, Labels: labels, Series: seriesKey(name, labels, nil), Value: value, Counter: counter})
+	}
+
+	for _, sel := range selectors {
+		family, ok := families[sel.name]
+		if !ok {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if !sel.matches(labels) {
+				continue
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(sel.name, labels, m.GetCounter().GetValue(), true)
+			case dto.MetricType_GAUGE:
+				add(sel.name, labels, m.GetGauge().GetValue(), false)
+			case dto.MetricType_UNTYPED:
+				add(sel.name, labels, m.GetUntyped().GetValue(), false)
+			case dto.MetricType_HISTOGRAM:
+				add(sel.name+"_count", labels, float64(m.GetHistogram().GetSampleCount()), true)
+				add(sel.name+"_sum", labels, m.GetHistogram().GetSampleSum(), true)
+			case dto.MetricType_GAUGE_HISTOGRAM:
+				add(sel.name+"_count", labels, float64(m.GetHistogram().GetSampleCount()), false)
+				add(sel.name+"_sum", labels, m.GetHistogram().GetSampleSum(), false)
+			case dto.MetricType_SUMMARY:
+				add(sel.name+"_count", labels, float64(m.GetSummary().GetSampleCount()), true)
+				add(sel.name+"_sum", labels, m.GetSummary().GetSampleSum(), true)
+			}
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Series < samples[j].Series
+	})
+	return samples
+}
+
+// Samples returns all samples scraped so far.
+func (s *MetricsScraper) Samples() []ScrapedSample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]ScrapedSample(nil), s.samples...)
+}
+
+// Last returns the last scraped value of every series, the increase over all scrapes for counters,
+// see LastSamples.
+func (s *MetricsScraper) Last() map[string]float64 {
+	samples := s.LastSamples()
+	if len(samples) == 0 {
+		return nil
+	}
+	last := make(map[string]float64, len(samples))
+	for _, sample := range samples {
+		last[sample.Series] = sample.Value
+	}
+	return last
+}
+
+// LastSamples returns the last scraped sample of every series, sorted by series. The value of a counter
+// is its increase over all scrapes, see counterTotals, so that a restart of the scraped process, whose
+// counters start again from zero, does not lose the counts before it.
+func (s *MetricsScraper) LastSamples() []ScrapedSample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	totals := counterTotals(s.samples, func(sample ScrapedSample) bool { return sample.Counter })
+	index := map[string]int{}
+	var last []ScrapedSample
+	for _, sample := range s.samples {
+		if i, ok := index[sample.Series]; ok {
+			last[i] = sample
+			continue
+		}
+		index[sample.Series] = len(last)
+		last = append(last, sample)
+	}
+	for i := range last {
+		if last[i].Counter {
+			last[i].Value = totals[last[i].Series]
+		}
+	}
+	sort.Slice(last, func(i, j int) bool {
+		return last[i].Series < last[j].Series
+	})
+	return last
+}
+
+// counterTotals returns the increase of every selected counter series over the samples, in scrape
+// order. A decrease is a restart of the scraped process, whose counters start again from zero.
+func counterTotals(samples []ScrapedSample, selected func(ScrapedSample) bool) map[string]float64 {
+	last := map[string]float64{}
+	totals := map[string]float64{}
+	for _, sample := range samples {
+		if !selected(sample) {
+			continue
+		}
+		if prev, ok := last[sample.Series]; ok && sample.Value >= prev {
+			totals[sample.Series] += sample.Value - prev
+		} else {
+			totals[sample.Series] += sample.Value
+		}
+		last[sample.Series] = sample.Value
+	}
+	return totals
+}
+
+// writeScrapedMetricsCSV writes the samples of the scraper to fileName, one row per sample, with the
+// time both as RFC 3339 timestamp and as seconds since start.
+func writeScrapedMetricsCSV(fileName string, start time.Time, scraper *MetricsScraper) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	_ = w.Write([]string{"timestamp", "elapsed_seconds", "series", "value"})
+	for _, sample := range scraper.Samples() {
+		_ = w.Write([]string{
+			sample.Time.UTC().Format(time.RFC3339Nano),
+			strconv.FormatFloat(sample.Time.Sub(start).Seconds(), 'f', 3, 64),
+			sample.Series,
+			strconv.FormatFloat(sample.Value, 'g', -1, 64),
+		})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSelfMetrics = `# HELP prometheus_tsdb_head_series Total number of series in the head block.
+# TYPE prometheus_tsdb_head_series gauge
+prometheus_tsdb_head_series %d
+# HELP prometheus_http_request_duration_seconds Histogram of latencies for HTTP requests.
+# TYPE prometheus_http_request_duration_seconds histogram
+prometheus_http_request_duration_seconds_bucket{handler="/api/v1/write",le="0.1"} 3
+prometheus_http_request_duration_seconds_bucket{handler="/api/v1/write",le="+Inf"} 4
+prometheus_http_request_duration_seconds_sum{handler="/api/v1/write"} 0.5
+prometheus_http_request_duration_seconds_count{handler="/api/v1/write"} 4
+prometheus_http_request_duration_seconds_bucket{handler="/metrics",le="0.1"} 1
+prometheus_http_request_duration_seconds_bucket{handler="/metrics",le="+Inf"} 1
+prometheus_http_request_duration_seconds_sum{handler="/metrics"} 0.01
+prometheus_http_request_duration_seconds_count{handler="/metrics"} 1
+# HELP go_threads Number of OS threads created.
+# TYPE go_threads gauge
+go_threads 12
+`
+
+func TestMetricsScraper(t *testing.T) {
+	scrapes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scrapes++
+		fmt.Fprintf(w, testSelfMetrics, 100*scrapes)
+	}))
+	defer server.Close()
+
+	scraper := NewMetricsScraper("test", server.URL, time.Hour, prometheusSelfMetrics)
+	scraper.Start()
+	scraper.Stop()
+
+	// One scrape at start and one at stop.
+	if scrapes != 2 {
+		t.Fatalf("got %d scrapes, want 2", scrapes)
+	}
+	if got := len(scraper.Samples()); got != 6 {
+		t.Errorf("got %d samples, want 6", got)
+	}
+
+	want := map[string]float64{
+		`prometheus_tsdb_head_series{}`:                                           200,
+		`prometheus_http_request_duration_seconds_count{handler="/api/v1/write"}`: 4,
+		`prometheus_http_request_duration_seconds_sum{handler="/api/v1/write"}`:   0.5,
+	}
+	for _, sample := range scraper.LastSamples() {
+		if counter := sample.Name != "prometheus_tsdb_head_series"; sample.Counter != counter {
+			t.Errorf("%s counter %t, want %t", sample.Series, sample.Counter, counter)
+		}
+	}
+	last := scraper.Last()
+	if len(last) != len(want) {
+		t.Errorf("got series %v, want %v", last, want)
+	}
+	for series, value := range want {
+		if last[series] != value {
+			t.Errorf("%s = %v, want %v", series, last[series], value)
+		}
+	}
+}
+
+func TestMetricsScraperLastSamplesRestart(t *testing.T) {
+	appended := `prometheus_tsdb_head_samples_appended_total{}`
+	scraper := &MetricsScraper{samples: []ScrapedSample{
+		{Name: "prometheus_tsdb_head_samples_appended_total", Series: appended, Value: 100, Counter: true},
+		{Name: "prometheus_tsdb_head_series", Series: "prometheus_tsdb_head_series{}", Value: 50},
+		{Name: "prometheus_tsdb_head_samples_appended_total", Series: appended, Value: 300, Counter: true},
+		// Prometheus restarted, its counters start from zero.
+		{Name: "prometheus_tsdb_head_samples_appended_total", Series: appended, Value: 20, Counter: true},
+		{Name: "prometheus_tsdb_head_series", Series: "prometheus_tsdb_head_series{}", Value: 40},
+		{Name: "prometheus_tsdb_head_samples_appended_total", Series: appended, Value: 120, Counter: true},
+	}}
+
+	last := scraper.Last()
+	if last[appended] != 420 || last["prometheus_tsdb_head_series{}"] != 40 {
+		t.Errorf("got %v, want the counter summed over the restart and the last gauge value", last)
+	}
+	if samples := scraper.Samples(); samples[len(samples)-1].Value != 120 {
+		t.Errorf("got scraped samples %v changed", samples)
+	}
+}
+
+func TestMetricsScraperUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	scraper := NewMetricsScraper("test", server.URL, time.Hour, prometheusSelfMetrics)
+	scraper.Start()
+	scraper.Stop()
+
+	if scraper.Last() != nil {
+		t.Errorf("got %v, want no samples", scraper.Last())
+	}
+}
+
+func TestMetricsScraperScrapeNow(t *testing.T) {
+	var scrapes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testSelfMetrics, 100*scrapes.Add(1))
+	}))
+	defer server.Close()
+
+	scraper := NewMetricsScraper("test", server.URL, time.Millisecond, prometheusSelfMetrics)
+	scraper.Start()
+	for i := 0; i < 10; i++ {
+		scraper.ScrapeNow()
+	}
+	scraper.Stop()
+
+	if got := len(scraper.Samples()); got != 3*int(scrapes.Load()) {
+		t.Errorf("got %d samples of %d scrapes, want 3 per scrape", got, scrapes.Load())
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistogramQueryResult is the result of a query over the histograms of the histogram provider.
+type HistogramQueryResult struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// Value is the single value of the result, nil if the result is empty.
+	Value          *float64 `json:"value,omitempty"`
+	LatencySeconds float64  `json:"latency_seconds"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// histogramQuery is a query over a histogram type, %s being replaced by the metric name.
+type histogramQuery struct {
+	Name  string
+	Query string
+}
+
+// histogramQueries are the queries compared between classic histograms, one series per bucket, and
+// native histograms, one series holding all buckets. Both answer the same questions.
+var histogramQueries = map[string][]histogramQuery{
+	histogramTypeExplicit: {
+		{Name: "p90", Query: `histogram_quantile(0.9, sum by (le) (rate(%s_bucket[1m])))`},
+		{Name: "rate", Query: `sum(rate(%s_count[1m]))`},
+		{Name: "average", Query: `sum(rate(%[1]s_sum[1m])) / sum(rate(%[1]s_count[1m]))`},
+	},
+	histogramTypeExponential: {
+		{Name: "p90", Query: `histogram_quantile(0.9, sum(rate(%s[1m])))`},
+		{Name: "rate", Query: `sum(histogram_count(rate(%s[1m])))`},
+		{Name: "average", Query: `sum(histogram_sum(rate(%[1]s[1m]))) / sum(histogram_count(rate(%[1]s[1m])))`},
+	},
+}
+
+// histogramMetricName returns the Prometheus name of the histograms of type typ generated by the
+// histogram provider, without the _bucket, _sum and _count suffixes of classic histograms.
+func histogramMetricName(typ string) string {
+	return "load_generator_" + typ + "_seconds"
+}
+
+// queryHistograms evaluates the histogram queries of every histogram type of types at ts.
+func queryHistograms(client *PrometheusClient, types []string, ts time.Time) []HistogramQueryResult {
+	var results []HistogramQueryResult
+	for _, typ := range types {
+		for _, q := range histogramQueries[typ] {
+			result := HistogramQueryResult{
+				Type:  typ,
+				Name:  q.Name,
+				Query: fmt.Sprintf(q.Query, histogramMetricName(typ)),
+			}
+			start := time.Now()
+			series, err := client.Query(result.Query, ts)
+			result.LatencySeconds = time.Since(start).Seconds()
+			switch {
+			case err != nil:
+				result.Error = err.Error()
+			case len(series) > 0 && len(series[0].Samples) > 0:
+				value := series[0].Samples[0].Value
+				result.Value = &value
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// nativeHistogramCells returns the cells of the native-histograms command: classic and exponential
+// histograms through remote write and native OTLP, Prometheus having native histograms enabled.
+func nativeHistogramCells(spec ScenarioSpec) []ScenarioSpec {
+	var cells []ScenarioSpec
+	for _, mode := range []IngestionMode{ModeRemoteWrite, ModeOTLPNative} {
+		for _, typ := range []string{histogramTypeExplicit, histogramTypeExponential} {
+			cell := spec
+			cell.Matrix = MatrixSpec{}
+			cell.Mode = mode
+			cell.Prometheus.NativeHistograms = true
+			cell.Load.Provider = "histograms"
+			cell.Load.Histograms.Types = []string{typ}
+			cell.Name = path.Join(spec.Name, fmt.Sprintf("%s-%s", mode, typ))
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// runNativeHistograms runs the scenario once per cell of nativeHistogramCells and compares the storage
+// cost, the resource usage of Prometheus and the query results. The comparison table is logged and
+// written to results/<name>/native_histograms.md.
+func runNativeHistograms(spec ScenarioSpec) ([]*ScenarioResult, error) {
+	cells := nativeHistogramCells(spec)
+	resultsSummary := &ScenarioResults{}
+	resultsSummary.Init(path.Join("results", spec.Name))
+
+	results := make([]*ScenarioResult, 0, len(cells))
+	var errs []error
+	for i, cell := range cells {
+		log.Printf("Native histograms cell %d/%d: %s", i+1, len(cells), cell.Name)
+		result := sendToPrometheus(cell, resultsSummary)
+		if result.ErrorCause != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", cell.Name, result.ErrorCause))
+		}
+		results = append(results, result)
+	}
+	resultsSummary.Save()
+
+	var table strings.Builder
+	writeNativeHistogramsTable(&table, results)
+	log.Printf("Native histograms results of %s:\n%s", spec.Name, table.String())
+
+	fileName := path.Join("results", spec.Name, "native_histograms.md")
+	if err := os.WriteFile(fileName, []byte(table.String()), 0644); err != nil {
+		errs = append(errs, err)
+	}
+	return results, errors.Join(errs...)
+}
+
+// writeNativeHistogramsTable writes one markdown table row per cell. Bytes per point divides the TSDB
+// size by the data points sent, a classic histogram point being stored as one sample per bucket plus
+// _sum and _count and a native histogram point as one sample.
+func writeNativeHistogramsTable(w io.Writer, results []*ScenarioResult) {
+	fmt.Fprint(w,
+		"Mode        |Type                 |Points Sent|TSDB MiB|Bytes/Point|Head Series|Prom CPU Avg%|Prom RAM Max MiB|p90 s   |Rate/s    |Average s|Query ms|Error\n"+
+			"------------|---------------------|----------:|-------:|----------:|----------:|------------:|---------------:|-------:|---------:|--------:|-------:|-----\n")
+	for _, r := range results {
+		var typ string
+		queries := map[string]HistogramQueryResult{}
+		var latency float64
+		for _, q := range r.HistogramQueries {
+			typ = q.Type
+			queries[q.Name] = q
+			latency += q.LatencySeconds
+		}
+		var promCPU float64
+		var promRAM uint32
+		if r.Prometheus != nil {
+			promCPU, promRAM = r.Prometheus.CPUPercentAvg, r.Prometheus.RAMMiBMax
+		}
+		var tsdbMiB, bytesPerPoint float64
+		var headSeries uint64
+		if r.TSDB != nil {
+			tsdbMiB = float64(r.TSDB.TotalBytes) / mibibyte
+			headSeries = r.TSDB.HeadSeries
+			if r.ItemsSent > 0 {
+				bytesPerPoint = float64(r.TSDB.TotalBytes) / float64(r.ItemsSent)
+			}
+		}
+		fmt.Fprintf(w, "%-12s|%-21s|%11d|%8.1f|%11.1f|%11d|%13.1f|%16d|%8s|%10s|%9s|%8.1f|%s\n",
+			r.Mode,
+			typ,
+			r.ItemsSent,
+			tsdbMiB,
+			bytesPerPoint,
+			headSeries,
+			promCPU,
+			promRAM,
+			formatQueryValue(queries["p90"].Value, 3),
+			formatQueryValue(queries["rate"].Value, 1),
+			formatQueryValue(queries["average"].Value, 3),
+			1000*latency,
+			r.ErrorCause,
+		)
+	}
+}
+
+// formatQueryValue formats the value of a query result with prec decimals, - for an empty result.
+func formatQueryValue(value *float64, prec int) string {
+	if value == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*value, 'f', prec, 64)
+}
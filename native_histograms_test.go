@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+)
+
+func TestNativeHistogramCells(t *testing.T) {
+	spec := DefaultScenarioSpec()
+	spec.Name = "native"
+	cells := nativeHistogramCells(spec)
+	if len(cells) != 4 {
+		t.Fatalf("got %d cells", len(cells))
+	}
+	cell := cells[3]
+	if cell.Name != "native/otlp-native-exponential_histogram" || cell.Mode != ModeOTLPNative || cell.Load.Provider != "histograms" ||
+		len(cell.Load.Histograms.Types) != 1 || cell.Load.Histograms.Types[0] != histogramTypeExponential {
+		t.Errorf("unexpected cell %s %s %+v", cell.Name, cell.Mode, cell.Load)
+	}
+	if args := cell.Prometheus.args(); args[len(args)-1] != "--enable-feature=native-histograms" {
+		t.Errorf("got args %v", args)
+	}
+}
+
+func TestQueryHistograms(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		switch {
+		case strings.HasPrefix(query, "histogram_quantile(0.9, sum(rate(load_generator_exponential_histogram_seconds[1m])))"):
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"2.5"]}]}}`)
+		case strings.Contains(query, "load_generator_histogram_seconds_bucket"):
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		default:
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+		}
+	}))
+	defer server.Close()
+
+	client := &PrometheusClient{baseURL: server.URL, httpClient: server.Client()}
+	results := queryHistograms(client, []string{histogramTypeExplicit, histogramTypeExponential}, time.Now())
+	if len(results) != 6 {
+		t.Fatalf("got %d results", len(results))
+	}
+	if results[0].Error == "" || results[0].Value != nil {
+		t.Errorf("classic p90 query did not fail: %+v", results[0])
+	}
+	if r := results[3]; r.Name != "p90" || r.Value == nil || *r.Value != 2.5 {
+		t.Errorf("unexpected native p90 result %+v", r)
+	}
+	if r := results[4]; r.Query != "sum(histogram_count(rate(load_generator_exponential_histogram_seconds[1m])))" || r.Value != nil || r.Error != "" {
+		t.Errorf("unexpected native rate result %+v", r)
+	}
+}
+
+func TestNativeHistogramsTable(t *testing.T) {
+	p90 := 2.5
+	results := []*ScenarioResult{{
+		Mode:             ModeOTLPNative,
+		ItemsSent:        1000,
+		Prometheus:       &testbed.ResourceConsumption{CPUPercentAvg: 12.5, RAMMiBMax: 200},
+		TSDB:             &TSDBFootprint{TotalBytes: 2 * mibibyte, HeadSeries: 10},
+		HistogramQueries: []HistogramQueryResult{{Type: histogramTypeExponential, Name: "p90", Value: &p90, LatencySeconds: 0.002}},
+	}}
+
+	var table strings.Builder
+	writeNativeHistogramsTable(&table, results)
+	want := "otlp-native |exponential_histogram|       1000|     2.0|     2097.2|         10|         12.5|             200|   2.500|         -|        -|     2.0|\n"
+	if !strings.HasSuffix(table.String(), want) {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+}
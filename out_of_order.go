@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// OutOfOrderSpec shifts the timestamps of the generated metrics into the past, the way batching and
+// retries in the collector delay and reorder data on its way to Prometheus.
+type OutOfOrderSpec struct {
+	// Delay shifts every batch into the past, so that samples arrive late but in order.
+	Delay time.Duration `yaml:"delay"`
+	// Ratio is the share of batches sent out of order, shifted back by a random duration of up to
+	// MaxShift more, behind the samples already sent for the same series.
+	Ratio    float64       `yaml:"ratio"`
+	MaxShift time.Duration `yaml:"max_shift"`
+	// Seed of the random choice of the out-of-order batches and their shift, the same seed shifts the
+	// same batches of a run.
+	Seed int64 `yaml:"seed"`
+}
+
+// Enabled returns true if any timestamp is shifted.
+func (o OutOfOrderSpec) Enabled() bool {
+	return o.Delay > 0 || o.Ratio > 0
+}
+
+// Validate checks the settings.
+func (o OutOfOrderSpec) Validate() error {
+	if o.Delay < 0 || o.MaxShift < 0 {
+		return errors.New("out_of_order delay and max_shift must not be negative")
+	}
+	if o.Ratio < 0 || o.Ratio > 1 {
+		return fmt.Errorf("out_of_order ratio %g out of range [0, 1]", o.Ratio)
+	}
+	if o.Ratio > 0 && o.MaxShift == 0 {
+		return errors.New("out_of_order max_shift must be greater than zero to send batches out of order")
+	}
+	return nil
+}
+
+// outOfOrderDataProvider wraps a DataProvider and shifts the timestamps of the metrics it generates
+// as configured by its OutOfOrderSpec. Traces and logs are passed through.
+type outOfOrderDataProvider struct {
+	testbed.DataProvider
+	spec OutOfOrderSpec
+
+	mutex  sync.Mutex
+	random *rand.Rand
+	// batches and items count all generated batches and data points, outOfOrderBatches and
+	// outOfOrderItems those shifted behind earlier ones.
+	batches           uint64
+	items             uint64
+	outOfOrderBatches uint64
+	outOfOrderItems   uint64
+}
+
+func newOutOfOrderDataProvider(provider testbed.DataProvider, spec OutOfOrderSpec) *outOfOrderDataProvider {
+	return &outOfOrderDataProvider{
+		DataProvider: provider,
+		spec:         spec,
+		// #nosec G404 -- the shifts only need to be reproducible, not unpredictable.
+		random: rand.New(rand.NewSource(spec.Seed)),
+	}
+}
+
+func (dp *outOfOrderDataProvider) GenerateMetrics() (pmetric.Metrics, bool) {
+	md, done := dp.DataProvider.GenerateMetrics()
+	items := uint64(md.DataPointCount())
+
+	shift := dp.spec.Delay
+	dp.mutex.Lock()
+	dp.batches++
+	dp.items += items
+	if dp.spec.Ratio > 0 && dp.random.Float64() < dp.spec.Ratio {
+		shift += time.Duration(dp.random.Int63n(int64(dp.spec.MaxShift))) + time.Millisecond
+		dp.outOfOrderBatches++
+		dp.outOfOrderItems += items
+	}
+	dp.mutex.Unlock()
+
+	shiftTimestamps(md, shift)
+	return md, done
+}
+
+// shiftTimestamps moves the timestamps and start timestamps of all data points of md back by shift.
+func shiftTimestamps(md pmetric.Metrics, shift time.Duration) {
+	if shift == 0 {
+		return
+	}
+	move := func(ts pcommon.Timestamp) pcommon.Timestamp {
+		if ts == 0 {
+			return ts
+		}
+		return pcommon.NewTimestampFromTime(ts.AsTime().Add(-shift))
+	}
+
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					shiftNumberDataPoints(metric.Gauge().DataPoints(), move)
+				case pmetric.MetricTypeSum:
+					shiftNumberDataPoints(metric.Sum().DataPoints(), move)
+				case pmetric.MetricTypeHistogram:
+					dps := metric.Histogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						dp.SetStartTimestamp(move(dp.StartTimestamp()))
+						dp.SetTimestamp(move(dp.Timestamp()))
+						shiftExemplars(dp.Exemplars(), move)
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					dps := metric.ExponentialHistogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						dp.SetStartTimestamp(move(dp.StartTimestamp()))
+						dp.SetTimestamp(move(dp.Timestamp()))
+						shiftExemplars(dp.Exemplars(), move)
+					}
+				case pmetric.MetricTypeSummary:
+					dps := metric.Summary().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						dp.SetStartTimestamp(move(dp.StartTimestamp()))
+						dp.SetTimestamp(move(dp.Timestamp()))
+					}
+				}
+			}
+		}
+	}
+}
+
+func shiftNumberDataPoints(dps pmetric.NumberDataPointSlice, move func(pcommon.Timestamp) pcommon.Timestamp) {
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		dp.SetStartTimestamp(move(dp.StartTimestamp()))
+		dp.SetTimestamp(move(dp.Timestamp()))
+		shiftExemplars(dp.Exemplars(), move)
+	}
+}
+
+func shiftExemplars(exemplars pmetric.ExemplarSlice, move func(pcommon.Timestamp) pcommon.Timestamp) {
+	for i := 0; i < exemplars.Len(); i++ {
+		exemplars.At(i).SetTimestamp(move(exemplars.At(i).Timestamp()))
+	}
+}
+
+// OutOfOrderReport compares the late and out-of-order data sent with the samples Prometheus accepted
+// and rejected, from its TSDB counters, and the points the collector exporter failed to send, as a
+// request with rejected samples fails as a whole.
+type OutOfOrderReport struct {
+	// Window is the storage.tsdb.out_of_order_time_window of Prometheus, out-of-order samples older
+	// than the newest sample of their series by more are rejected.
+	Window string `json:"window"`
+	// Batches and Items count all generated batches and data points, OutOfOrderBatches and
+	// OutOfOrderItems those sent behind earlier ones.
+	Batches           uint64 `json:"batches"`
+	Items             uint64 `json:"items"`
+	OutOfOrderBatches uint64 `json:"out_of_order_batches"`
+	OutOfOrderItems   uint64 `json:"out_of_order_items"`
+	// SamplesAppended is prometheus_tsdb_head_samples_appended_total, in order and out-of-order samples,
+	// OutOfOrderAppended prometheus_tsdb_head_out_of_order_samples_appended_total.
+	SamplesAppended    float64 `json:"samples_appended"`
+	OutOfOrderAppended float64 `json:"out_of_order_appended"`
+	// OutOfOrderRejected is prometheus_tsdb_out_of_order_samples_total, TooOldRejected
+	// prometheus_tsdb_too_old_samples_total, the samples older than the window.
+	OutOfOrderRejected float64 `json:"out_of_order_rejected"`
+	TooOldRejected     float64 `json:"too_old_rejected"`
+	// Exporter is the collector exporter to Prometheus and ExporterSentPoints and ExporterFailedPoints
+	// its counters.
+	Exporter             string  `json:"exporter,omitempty"`
+	ExporterSentPoints   float64 `json:"exporter_sent_points"`
+	ExporterFailedPoints float64 `json:"exporter_failed_points"`
+}
+
+// newOutOfOrderReport combines the counters of the provider with the last scraped Prometheus samples,
+// counters summed over restarts as by MetricsScraper.LastSamples, and the stats of the exporter to Prometheus.
+func newOutOfOrderReport(dp *outOfOrderDataProvider, window time.Duration, promSamples []ScrapedSample,
+	exporters []ExporterStats, exporter string) *OutOfOrderReport {
+	dp.mutex.Lock()
+	report := &OutOfOrderReport{
+		Window:            window.String(),
+		Batches:           dp.batches,
+		Items:             dp.items,
+		OutOfOrderBatches: dp.outOfOrderBatches,
+		OutOfOrderItems:   dp.outOfOrderItems,
+		Exporter:          exporter,
+	}
+	dp.mutex.Unlock()
+
+	// Recent Prometheus versions split the counters by sample type, float and histogram.
+	for _, sample := range promSamples {
+		switch sample.Name {
+		case "prometheus_tsdb_head_samples_appended_total":
+			report.SamplesAppended += sample.Value
+		case "prometheus_tsdb_head_out_of_order_samples_appended_total":
+			report.OutOfOrderAppended += sample.Value
+		case "prometheus_tsdb_out_of_order_samples_total":
+			report.OutOfOrderRejected += sample.Value
+		case "prometheus_tsdb_too_old_samples_total":
+			report.TooOldRejected += sample.Value
+		}
+	}
+	for _, stats := range exporters {
+		if stats.Exporter == exporter {
+			report.ExporterSentPoints = stats.SentPoints
+			report.ExporterFailedPoints = stats.SendFailedPoints + stats.EnqueueFailedPoints
+		}
+	}
+	return report
+}
+
+// Rejected returns the samples Prometheus rejected for being out of order or too old.
+func (r *OutOfOrderReport) Rejected() float64 {
+	return r.OutOfOrderRejected + r.TooOldRejected
+}
+
+func (r *OutOfOrderReport) String() string {
+	return fmt.Sprintf("window:%s out-of-order batches:%d/%d items:%d/%d appended:%.0f out-of-order appended:%.0f rejected out-of-order:%.0f too old:%.0f exporter %s sent:%.0f failed:%.0f",
+		r.Window, r.OutOfOrderBatches, r.Batches, r.OutOfOrderItems, r.Items, r.SamplesAppended, r.OutOfOrderAppended,
+		r.OutOfOrderRejected, r.TooOldRejected, r.Exporter, r.ExporterSentPoints, r.ExporterFailedPoints)
+}
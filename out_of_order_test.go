@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestOutOfOrderDataProvider(t *testing.T) {
+	spec := OutOfOrderSpec{Delay: time.Minute, Ratio: 0.5, MaxShift: time.Minute, Seed: 1}
+	if err := spec.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	options := testbed.LoadOptions{ItemsPerBatch: 10}
+	cardinality := CardinalitySpec{Series: 10, Metrics: 1}
+	provider := newOutOfOrderDataProvider(NewCardinalityDataProvider(options, cardinality), spec)
+	var generated atomic.Uint64
+	provider.SetLoadGeneratorCounters(&generated)
+
+	for i := 0; i < 100; i++ {
+		before := time.Now()
+		md, _ := provider.GenerateMetrics()
+		ts := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Timestamp()
+
+		if age := before.Sub(ts.AsTime()); age < time.Minute-time.Second || age > 2*time.Minute+time.Second {
+			t.Fatalf("batch %d is %s old, want between the delay and the delay plus max_shift", i, age)
+		}
+	}
+
+	if generated.Load() != 1000 || provider.batches != 100 || provider.items != 1000 {
+		t.Errorf("got %d batches with %d items", provider.batches, provider.items)
+	}
+	if provider.outOfOrderBatches < 30 || provider.outOfOrderBatches > 70 || provider.outOfOrderItems != 10*provider.outOfOrderBatches {
+		t.Errorf("got %d out-of-order batches with %d items, want about half", provider.outOfOrderBatches, provider.outOfOrderItems)
+	}
+
+	// The same seed shifts the same batches.
+	again := newOutOfOrderDataProvider(NewCardinalityDataProvider(options, cardinality), spec)
+	for i := 0; i < 100; i++ {
+		again.GenerateMetrics()
+	}
+	if again.outOfOrderBatches != provider.outOfOrderBatches {
+		t.Errorf("got %d out-of-order batches, then %d with the same seed", provider.outOfOrderBatches, again.outOfOrderBatches)
+	}
+}
+
+func TestShiftTimestamps(t *testing.T) {
+	provider := NewHistogramDataProvider(testbed.LoadOptions{ItemsPerBatch: 4}, HistogramSpec{
+		Types:              []string{histogramTypeExplicit, histogramTypeExponential, histogramTypeSummary, histogramTypeSum},
+		Temporality:        temporalityCumulative,
+		Buckets:            2,
+		ExponentialBuckets: 2,
+		Quantiles:          []float64{0.5},
+	})
+	md, _ := provider.GenerateMetrics()
+	original := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Histogram().DataPoints().At(0)
+	start, ts := original.StartTimestamp().AsTime(), original.Timestamp().AsTime()
+
+	shiftTimestamps(md, time.Hour)
+
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	histogram := metrics.At(0).Histogram().DataPoints().At(0)
+	if !histogram.StartTimestamp().AsTime().Equal(start.Add(-time.Hour)) || !histogram.Timestamp().AsTime().Equal(ts.Add(-time.Hour)) {
+		t.Errorf("histogram not shifted: %s %s", histogram.StartTimestamp(), histogram.Timestamp())
+	}
+	for _, got := range []pcommon.Timestamp{
+		metrics.At(1).ExponentialHistogram().DataPoints().At(0).Timestamp(),
+		metrics.At(2).Summary().DataPoints().At(0).Timestamp(),
+		metrics.At(3).Sum().DataPoints().At(0).Timestamp(),
+	} {
+		if !got.AsTime().Equal(ts.Add(-time.Hour)) {
+			t.Errorf("got timestamp %s, want %s", got.AsTime(), ts.Add(-time.Hour))
+		}
+	}
+}
+
+func TestOutOfOrderSpecInvalid(t *testing.T) {
+	for name, spec := range map[string]OutOfOrderSpec{
+		"negative delay": {Delay: -time.Second},
+		"ratio above 1":  {Ratio: 2, MaxShift: time.Second},
+		"no max shift":   {Ratio: 0.5},
+	} {
+		if err := spec.Validate(); err == nil {
+			t.Errorf("%s: expected error for %+v", name, spec)
+		}
+	}
+}
+
+func TestOutOfOrderReport(t *testing.T) {
+	provider := newOutOfOrderDataProvider(NewCardinalityDataProvider(testbed.LoadOptions{ItemsPerBatch: 5}, CardinalitySpec{Series: 5, Metrics: 1}),
+		OutOfOrderSpec{Ratio: 1, MaxShift: time.Second})
+	provider.GenerateMetrics()
+	provider.GenerateMetrics()
+
+	report := newOutOfOrderReport(provider, 0, []ScrapedSample{
+		{Name: "prometheus_tsdb_head_samples_appended_total", Labels: map[string]string{"type": "float"}, Value: 5},
+		{Name: "prometheus_tsdb_out_of_order_samples_total", Labels: map[string]string{"type": "float"}, Value: 3},
+		{Name: "prometheus_tsdb_out_of_order_samples_total", Labels: map[string]string{"type": "histogram"}, Value: 1},
+		{Name: "prometheus_tsdb_too_old_samples_total", Value: 1},
+	}, []ExporterStats{
+		{Exporter: "otlphttp", SentPoints: 10},
+		{Exporter: "prometheusremotewrite", SentPoints: 5, SendFailedPoints: 5},
+	}, prometheusExporterName(ModeRemoteWrite))
+
+	if report.OutOfOrderBatches != 2 || report.OutOfOrderItems != 10 || report.Window != "0s" {
+		t.Errorf("unexpected sent counters %+v", report)
+	}
+	if report.SamplesAppended != 5 || report.OutOfOrderRejected != 4 || report.Rejected() != 5 {
+		t.Errorf("unexpected Prometheus counters %+v", report)
+	}
+	if report.ExporterSentPoints != 5 || report.ExporterFailedPoints != 5 {
+		t.Errorf("unexpected exporter counters %+v", report)
+	}
+}
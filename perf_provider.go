@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strconv"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// perfDataProvider wraps testbed.NewPerfTestDataProvider, whose gauges have neither a name nor a
+// timestamp: they would be stored as the series "ratio" at time 0, which Prometheus rejects as out of
+// bounds. The metrics are named load_generator_perf_<index in the batch> and their data points
+// timestamped with the start timestamp the testbed sets to the generation time.
+type perfDataProvider struct {
+	testbed.DataProvider
+}
+
+// NewPerfDataProvider creates the perf provider of the given options.
+func NewPerfDataProvider(options testbed.LoadOptions) testbed.DataProvider {
+	return &perfDataProvider{DataProvider: testbed.NewPerfTestDataProvider(options)}
+}
+
+func (dp *perfDataProvider) GenerateMetrics() (pmetric.Metrics, bool) {
+	md, done := dp.DataProvider.GenerateMetrics()
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				if metric.Name() == "" {
+					metric.SetName("load_generator_perf_" + strconv.Itoa(k))
+				}
+				if metric.Type() == pmetric.MetricTypeGauge {
+					setPerfTimestamps(metric.Gauge().DataPoints())
+				}
+			}
+		}
+	}
+	return md, done
+}
+
+// setPerfTimestamps sets the timestamp of the data points without one to their start timestamp.
+func setPerfTimestamps(dps pmetric.NumberDataPointSlice) {
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		if dp.Timestamp() == 0 {
+			dp.SetTimestamp(dp.StartTimestamp())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// generatePerfBatches returns batches of the perf provider and the number of data points generated.
+func generatePerfBatches(batches int) ([]pmetric.Metrics, uint64) {
+	provider := NewPerfDataProvider(testbed.LoadOptions{ItemsPerBatch: 3})
+	var generated atomic.Uint64
+	provider.SetLoadGeneratorCounters(&generated)
+
+	var mds []pmetric.Metrics
+	for i := 0; i < batches; i++ {
+		md, _ := provider.GenerateMetrics()
+		mds = append(mds, md)
+	}
+	return mds, generated.Load()
+}
+
+func TestPerfDataProviderExpectedData(t *testing.T) {
+	start := time.Now()
+	batches, generated := generatePerfBatches(2)
+	end := time.Now()
+
+	data := newExpectedData(batches)
+	if data.untranslatable != 0 {
+		t.Errorf("got %d untranslatable points, want 0", data.untranslatable)
+	}
+	samples := 0
+	for _, name := range []string{"load_generator_perf_0_ratio", "load_generator_perf_1_ratio", "load_generator_perf_2_ratio"} {
+		if len(data.series[name]) == 0 {
+			t.Errorf("got no series %s in %v", name, data.series)
+		}
+	}
+	for name, byKey := range data.series {
+		for key, series := range byKey {
+			for ts := range series.samples {
+				samples++
+				if ts < start.UnixMilli() || ts > end.UnixMilli() {
+					t.Errorf("%s of %s at %d, want the generation time", key, name, ts)
+				}
+			}
+		}
+	}
+	if uint64(samples) != generated {
+		t.Errorf("got %d expected samples, want one per generated point %d", samples, generated)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -52,6 +53,10 @@ type PrometheusRunner struct {
 	// Resource specification that must be monitored for.
 	resourceSpec *testbed.ResourceSpec
 
+	// mutex guards the process monitoring data below, written by Start and the resource watcher of
+	// every started process and read by GetProcessMon and GetTotalConsumption.
+	mutex sync.Mutex
+
 	// Process monitoring data.
 	processMon *process.Process
 
@@ -81,27 +86,11 @@ type PrometheusRunner struct {
 
 	// Maximum RAM seen
 	ramMiBMax uint32
-}
 
-func createConfigPrometheusYaml() string {
-	format := `
-global:
-  scrape_interval: 15s # Set the scrape interval to every 15 seconds. Default is every 1 minute.
-  evaluation_interval: 15s # Evaluate rules every 15 seconds. The default is every 1 minute.
-
-scrape_configs:
-  - job_name: "prometheus"
-    # metrics_path defaults to '/metrics'
-    # scheme defaults to 'http'.
-    static_configs:
-    - targets: ["localhost:%d"]
-`
-
-	// Put corresponding elements into the config template to generate the final config.
-	return fmt.Sprintf(
-		format,
-		PortPrometheus,
-	)
+	// CPU seconds used and seconds monitored of the processes stopped before the current one, so that
+	// the average CPU usage covers every process started by the runner.
+	cpuSecondsBefore     float64
+	elapsedSecondsBefore float64
 }
 
 // OtelcolRunner defines the interface for configuring, starting and stopping one or more instances of
@@ -131,7 +120,7 @@ scrape_configs:
 type PrometheusRunnerOption func(*PrometheusRunner)
 
 // NewPrometheusRunner creates a new OtelcolRunner as a child process on the same machine executing the test.
-func NewPrometheusRunner(options ...PrometheusRunnerOption) testbed.OtelcolRunner {
+func NewPrometheusRunner(options ...PrometheusRunnerOption) *PrometheusRunner {
 	col := &PrometheusRunner{}
 
 	for _, option := range options {
@@ -177,6 +166,19 @@ func (cp *PrometheusRunner) PrepareConfig(configStr string) (configCleanup func(
 	return configCleanup, err
 }
 
+// PreparePrometheusConfig checks the config and stores it in the config file passed to Prometheus, like PrepareConfig.
+func (cp *PrometheusRunner) PreparePrometheusConfig(config *PrometheusConfig) (configCleanup func(), err error) {
+	configStr, err := config.YAML()
+	if err != nil {
+		return func() {}, fmt.Errorf("cannot render Prometheus config: %w", err)
+	}
+	log.Printf("Prom Config: %s", configStr)
+	if err = checkPrometheusConfig(configStr, cp.agentExePath); err != nil {
+		return func() {}, fmt.Errorf("invalid Prometheus config: %w", err)
+	}
+	return cp.PrepareConfig(configStr)
+}
+
 func expandExeFileName(exeName string) string {
 	cfgTemplate, err := template.New("").Parse(exeName)
 	if err != nil {
@@ -211,11 +213,25 @@ func expandExeFileName(exeName string) string {
 // logFilePath is the file path to write the standard output and standard error of
 // the process to.
 // cmdArgs is the command line arguments to pass to the process.
+//
+// A stopped process can be started again, e.g. to restart Prometheus during a test.
 func (cp *PrometheusRunner) Start(params testbed.StartParams) error {
+	if cp.isStarted && !cp.isStopped {
+		return fmt.Errorf("%s is already running", cp.name)
+	}
 
 	cp.name = params.Name
+	cp.isStarted = false
+	cp.isStopped = false
+	cp.stopOnce = sync.Once{}
+
+	cp.mutex.Lock()
+	// Every resource watcher stops on the signal of its own process.
 	cp.doneSignal = make(chan struct{})
 	cp.resourceSpec = params.GetResourceSpec()
+	cp.accumulateCPUUsage()
+	cp.processMon = nil
+	cp.mutex.Unlock()
 
 	// if cp.agentExePath == "" {
 	// 	cp.agentExePath = GlobalConfig.DefaultAgentExeRelativeFile
@@ -250,18 +266,21 @@ func (cp *PrometheusRunner) Start(params testbed.StartParams) error {
 		args = append(args, cp.configFileName)
 	}
 	// #nosec
-	cp.cmd = exec.Command(exePath, args...)
+	cmd := exec.Command(exePath, args...)
 
 	// Capture standard output and standard error.
-	cp.cmd.Stdout = logFile
-	cp.cmd.Stderr = logFile
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
 
 	// Start the process.
-	if err = cp.cmd.Start(); err != nil {
+	if err = cmd.Start(); err != nil {
 		return fmt.Errorf("cannot start executable at %s: %w", exePath, err)
 	}
 
+	cp.mutex.Lock()
+	cp.cmd = cmd
 	cp.startTime = time.Now()
+	cp.mutex.Unlock()
 	cp.isStarted = true
 
 	log.Printf("%s running, pid=%d", cp.name, cp.cmd.Process.Pid)
@@ -336,36 +355,47 @@ func resourceSpecisSpecified(rs *testbed.ResourceSpec) bool {
 	return rs != nil && (rs.ExpectedMaxCPU != 0 || rs.ExpectedMaxRAM != 0)
 }
 
+// WatchResourceConsumption monitors the process started last until it is stopped. A process started
+// again after Stop is monitored by another call.
 func (cp *PrometheusRunner) WatchResourceConsumption() error {
-	if !resourceSpecisSpecified(cp.resourceSpec) {
+	cp.mutex.Lock()
+	resourceSpec := cp.resourceSpec
+	doneSignal := cp.doneSignal
+	pid := cp.cmd.Process.Pid
+	cp.mutex.Unlock()
+
+	if !resourceSpecisSpecified(resourceSpec) {
 		// Resource monitoring is not enabled.
 		return nil
 	}
 
-	var err error
-	cp.processMon, err = process.NewProcess(int32(cp.cmd.Process.Pid))
+	proc, err := process.NewProcess(int32(pid))
 	if err != nil {
-		return fmt.Errorf("cannot monitor process %d: %w", cp.cmd.Process.Pid, err)
+		return fmt.Errorf("cannot monitor process %d: %w", pid, err)
 	}
 
-	cp.fetchRAMUsage()
-
 	// Begin measuring elapsed and process CPU times.
-	cp.lastElapsedTime = time.Now()
-	cp.lastProcessTimes, err = cp.processMon.Times()
+	times, err := proc.Times()
 	if err != nil {
-		return fmt.Errorf("cannot get process times for %d: %w", cp.cmd.Process.Pid, err)
+		return fmt.Errorf("cannot get process times for %d: %w", pid, err)
 	}
+	cp.mutex.Lock()
+	cp.processMon = proc
+	cp.lastElapsedTime = time.Now()
+	cp.lastProcessTimes = times
+	cp.mutex.Unlock()
+
+	cp.fetchRAMUsage(proc)
 
 	// Measure every ResourceCheckPeriod.
-	ticker := time.NewTicker(cp.resourceSpec.ResourceCheckPeriod)
+	ticker := time.NewTicker(resourceSpec.ResourceCheckPeriod)
 	defer ticker.Stop()
 
 	// on first start must be under the cpu and ram max usage add a max minute delay
 	for start := time.Now(); time.Since(start) < time.Minute; {
-		cp.fetchRAMUsage()
-		cp.fetchCPUUsage()
-		if err := cp.checkAllowedResourceUsage(); err != nil {
+		cp.fetchRAMUsage(proc)
+		cp.fetchCPUUsage(proc)
+		if err := cp.checkAllowedResourceUsage(resourceSpec); err != nil {
 			log.Printf("Allowed usage of resources is too high before test starts wait for one second : %v", err)
 			time.Sleep(time.Second)
 		} else {
@@ -373,14 +403,14 @@ func (cp *PrometheusRunner) WatchResourceConsumption() error {
 		}
 	}
 
-	remainingFailures := cp.resourceSpec.MaxConsecutiveFailures
+	remainingFailures := resourceSpec.MaxConsecutiveFailures
 	for {
 		select {
 		case <-ticker.C:
-			cp.fetchRAMUsage()
-			cp.fetchCPUUsage()
+			cp.fetchRAMUsage(proc)
+			cp.fetchCPUUsage(proc)
 
-			if err := cp.checkAllowedResourceUsage(); err != nil {
+			if err := cp.checkAllowedResourceUsage(resourceSpec); err != nil {
 				if remainingFailures > 0 {
 					remainingFailures--
 					log.Printf("Resource utilization too high. Remaining attempts: %d", remainingFailures)
@@ -392,28 +422,39 @@ func (cp *PrometheusRunner) WatchResourceConsumption() error {
 				return err
 			}
 
-		case <-cp.doneSignal:
+		case <-doneSignal:
 			log.Printf("Stopping process monitor.")
 			return nil
 		}
 	}
 }
 
+// GetProcessMon returns the monitored process, nil while a process is starting or not monitored.
+// It is called concurrently with Start by the ResourceSampler.
 func (cp *PrometheusRunner) GetProcessMon() *process.Process {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
 	return cp.processMon
 }
 
-func (cp *PrometheusRunner) fetchRAMUsage() {
+func (cp *PrometheusRunner) fetchRAMUsage(proc *process.Process) {
 	// Get process memory and CPU times
-	mi, err := cp.processMon.MemoryInfo()
+	mi, err := proc.MemoryInfo()
 	if err != nil {
-		log.Printf("cannot get process memory for %d: %v", cp.cmd.Process.Pid, err)
+		log.Printf("cannot get process memory for %d: %v", proc.Pid, err)
 		return
 	}
 
 	// Calculate RSS in MiBs.
 	ramMiBCur := uint32(mi.RSS / mibibyte)
 
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	if proc != cp.processMon {
+		// The process was stopped and another one started.
+		return
+	}
+
 	// Calculate aggregates.
 	cp.memProbeCount++
 	cp.ramMiBTotal += uint64(ramMiBCur)
@@ -425,15 +466,22 @@ func (cp *PrometheusRunner) fetchRAMUsage() {
 	cp.ramMiBCur.Store(ramMiBCur)
 }
 
-func (cp *PrometheusRunner) fetchCPUUsage() {
-	times, err := cp.processMon.Times()
+func (cp *PrometheusRunner) fetchCPUUsage(proc *process.Process) {
+	times, err := proc.Times()
 	if err != nil {
-		log.Printf("cannot get process times for %d: %v", cp.cmd.Process.Pid, err)
+		log.Printf("cannot get process times for %d: %v", proc.Pid, err)
 		return
 	}
 
 	now := time.Now()
 
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	if proc != cp.processMon {
+		// The process was stopped and another one started.
+		return
+	}
+
 	// Calculate elapsed and process CPU time deltas in seconds
 	deltaElapsedTime := now.Sub(cp.lastElapsedTime).Seconds()
 	deltaCPUTime := totalCPU(times) - totalCPU(cp.lastProcessTimes)
@@ -457,19 +505,19 @@ func (cp *PrometheusRunner) fetchCPUUsage() {
 	cp.cpuPercentX1000Cur.Store(curCPUPercentageX1000)
 }
 
-func (cp *PrometheusRunner) checkAllowedResourceUsage() error {
+func (cp *PrometheusRunner) checkAllowedResourceUsage(resourceSpec *testbed.ResourceSpec) error {
 	// Check if current CPU usage exceeds expected.
 	var errMsg string
-	if cp.resourceSpec.ExpectedMaxCPU != 0 && cp.cpuPercentX1000Cur.Load()/1000 > cp.resourceSpec.ExpectedMaxCPU {
+	if resourceSpec.ExpectedMaxCPU != 0 && cp.cpuPercentX1000Cur.Load()/1000 > resourceSpec.ExpectedMaxCPU {
 		errMsg = fmt.Sprintf("CPU consumption is %.1f%%, max expected is %d%%",
-			float64(cp.cpuPercentX1000Cur.Load())/1000.0, cp.resourceSpec.ExpectedMaxCPU)
+			float64(cp.cpuPercentX1000Cur.Load())/1000.0, resourceSpec.ExpectedMaxCPU)
 	}
 
 	// Check if current RAM usage exceeds expected.
-	if cp.resourceSpec.ExpectedMaxRAM != 0 && cp.ramMiBCur.Load() > cp.resourceSpec.ExpectedMaxRAM {
+	if resourceSpec.ExpectedMaxRAM != 0 && cp.ramMiBCur.Load() > resourceSpec.ExpectedMaxRAM {
 		formattedCurRAM := strconv.FormatUint(uint64(cp.ramMiBCur.Load()), 10)
 		errMsg = fmt.Sprintf("RAM consumption is %s MiB, max expected is %d MiB",
-			formattedCurRAM, cp.resourceSpec.ExpectedMaxRAM)
+			formattedCurRAM, resourceSpec.ExpectedMaxRAM)
 	}
 
 	if errMsg == "" {
@@ -483,7 +531,10 @@ func (cp *PrometheusRunner) checkAllowedResourceUsage() error {
 
 // GetResourceConsumption returns resource consumption as a string
 func (cp *PrometheusRunner) GetResourceConsumption() string {
-	if !resourceSpecisSpecified(cp.resourceSpec) {
+	cp.mutex.Lock()
+	resourceSpec := cp.resourceSpec
+	cp.mutex.Unlock()
+	if !resourceSpecisSpecified(resourceSpec) {
 		// Monitoring is not enabled.
 		return ""
 	}
@@ -495,36 +546,43 @@ func (cp *PrometheusRunner) GetResourceConsumption() string {
 		curRSSMib, float64(curCPUPercentageX1000)/1000.0)
 }
 
-// GetTotalConsumption returns total resource consumption since start of process
+// GetTotalConsumption returns total resource consumption of all processes started by the runner
 func (cp *PrometheusRunner) GetTotalConsumption() *testbed.ResourceConsumption {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
 	rc := &testbed.ResourceConsumption{}
 
-	if cp.processMon != nil {
-		// Get total elapsed time since process start
-		elapsedDuration := cp.lastElapsedTime.Sub(cp.startTime).Seconds()
+	// Get total CPU and elapsed time since start of the processes
+	cpuSeconds, elapsedDuration := cp.cpuSecondsBefore, cp.elapsedSecondsBefore
+	if cp.lastProcessTimes != nil {
+		cpuSeconds += totalCPU(cp.lastProcessTimes)
+		elapsedDuration += cp.lastElapsedTime.Sub(cp.startTime).Seconds()
+	}
 
-		if elapsedDuration > 0 {
-			// Calculate average CPU usage since start of process
-			rc.CPUPercentAvg = totalCPU(cp.lastProcessTimes) / elapsedDuration * 100.0
-		}
-		rc.CPUPercentMax = cp.cpuPercentMax
+	if elapsedDuration > 0 {
+		// Calculate average CPU usage since start of the processes
+		rc.CPUPercentAvg = cpuSeconds / elapsedDuration * 100.0
+	}
+	rc.CPUPercentMax = cp.cpuPercentMax
 
-		if cp.memProbeCount > 0 {
-			// Calculate average RAM usage by averaging all RAM measurements
-			rc.RAMMiBAvg = uint32(cp.ramMiBTotal / uint64(cp.memProbeCount))
-		}
-		rc.RAMMiBMax = cp.ramMiBMax
+	if cp.memProbeCount > 0 {
+		// Calculate average RAM usage by averaging all RAM measurements
+		rc.RAMMiBAvg = uint32(cp.ramMiBTotal / uint64(cp.memProbeCount))
 	}
+	rc.RAMMiBMax = cp.ramMiBMax
 
 	return rc
 }
 
-func (cp *PrometheusRunner) CleanDataDir(dataDirPath string) {
-	dataDirPath, err := filepath.Abs(dataDirPath)
-	if err != nil {
+// accumulateCPUUsage adds the CPU and elapsed time of the monitored process to the times of the
+// processes stopped before, before another process is started. Call it with the mutex held.
+func (cp *PrometheusRunner) accumulateCPUUsage() {
+	if cp.lastProcessTimes == nil {
 		return
 	}
-	os.RemoveAll(dataDirPath)
+	cp.cpuSecondsBefore += totalCPU(cp.lastProcessTimes)
+	cp.elapsedSecondsBefore += cp.lastElapsedTime.Sub(cp.startTime).Seconds()
+	cp.lastProcessTimes = nil
 }
 
 func containsConfig(s []string) bool {
@@ -536,6 +594,19 @@ func containsConfig(s []string) bool {
 	return false
 }
 
+// flagValue returns the value of the command line flag name given as "name=value" or "name value".
+func flagValue(args []string, name string) (string, bool) {
+	for i, a := range args {
+		if value, ok := strings.CutPrefix(a, name+"="); ok {
+			return value, true
+		}
+		if a == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
 // Copied from cpu.TimesStat.Total(), since that func is deprecated.
 func totalCPU(c *cpu.TimesStat) float64 {
 	total := c.User + c.System + c.Idle + c.Nice + c.Iowait + c.Irq +
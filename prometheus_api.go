@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrometheusClient queries the Prometheus HTTP API.
+type PrometheusClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewPrometheusClient creates a client for the Prometheus listening on host:port.
+func NewPrometheusClient(host string, port int) *PrometheusClient {
+	return &PrometheusClient{
+		baseURL:    fmt.Sprintf("http://%s:%d", host, port),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// apiResponse is the envelope of all /api/v1 responses.
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+// promSample is one sample of a matrix result.
+type promSample struct {
+	// Timestamp in milliseconds.
+	Timestamp int64
+	Value     float64
+	// Histogram is true for native histogram samples, Value is not set for them.
+	Histogram bool
+}
+
+// promSeries is one series of a matrix or vector result.
+type promSeries struct {
+	Labels  map[string]string
+	Samples []promSample
+}
+
+// get sends a GET request to the API path and decodes the data of the response into data.
+func (c *PrometheusClient) get(apiPath string, params url.Values, data interface{}) error {
+	resp, err := c.httpClient.Get(c.baseURL + apiPath + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope apiResponse
+	if err = json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("%s returned %s: %w", apiPath, resp.Status, err)
+	}
+	if envelope.Status != "success" {
+		return fmt.Errorf("%s failed with %s: %s", apiPath, envelope.ErrorType, envelope.Error)
+	}
+	return json.Unmarshal(envelope.Data, data)
+}
+
+// CheckEndpoint returns nil if the management endpoint, e.g. /-/ready, answers 200 OK.
+func (c *PrometheusClient) CheckEndpoint(endpoint string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Series returns the label sets of the series matching any of the matchers between start and end.
+func (c *PrometheusClient) Series(matchers []string, start, end time.Time) ([]map[string]string, error) {
+	params := url.Values{}
+	for _, matcher := range matchers {
+		params.Add("match[]", matcher)
+	}
+	params.Set("start", formatAPITime(start))
+	params.Set("end", formatAPITime(end))
+
+	var series []map[string]string
+	err := c.get("/api/v1/series", params, &series)
+	return series, err
+}
+
+// Query evaluates an instant query at ts. Range vector selectors return all raw samples of the range.
+func (c *PrometheusClient) Query(query string, ts time.Time) ([]promSeries, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatAPITime(ts))
+
+	var data struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric     map[string]string   `json:"metric"`
+			Value      []json.RawMessage   `json:"value"`
+			Values     [][]json.RawMessage `json:"values"`
+			Histogram  []json.RawMessage   `json:"histogram"`
+			Histograms [][]json.RawMessage `json:"histograms"`
+		} `json:"result"`
+	}
+	if err := c.get("/api/v1/query", params, &data); err != nil {
+		return nil, err
+	}
+
+	result := make([]promSeries, 0, len(data.Result))
+	for _, r := range data.Result {
+		series := promSeries{Labels: r.Metric}
+		if r.Value != nil {
+			r.Values = append(r.Values, r.Value)
+		}
+		if r.Histogram != nil {
+			r.Histograms = append(r.Histograms, r.Histogram)
+		}
+		for _, pair := range r.Values {
+			sample, err := parseSamplePair(pair, false)
+			if err != nil {
+				return nil, err
+			}
+			series.Samples = append(series.Samples, sample)
+		}
+		for _, pair := range r.Histograms {
+			sample, err := parseSamplePair(pair, true)
+			if err != nil {
+				return nil, err
+			}
+			series.Samples = append(series.Samples, sample)
+		}
+		result = append(result, series)
+	}
+	return result, nil
+}
+
+// promExemplar is one exemplar of a series.
+type promExemplar struct {
+	Labels map[string]string
+	Value  float64
+	// Timestamp in milliseconds.
+	Timestamp int64
+}
+
+// promExemplarSeries are the exemplars stored for a series.
+type promExemplarSeries struct {
+	Labels    map[string]string
+	Exemplars []promExemplar
+}
+
+// QueryExemplars returns the exemplars of the series selected by query between start and end. Prometheus
+// only stores exemplars with --enable-feature=exemplar-storage.
+func (c *PrometheusClient) QueryExemplars(query string, start, end time.Time) ([]promExemplarSeries, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatAPITime(start))
+	params.Set("end", formatAPITime(end))
+
+	var data []struct {
+		SeriesLabels map[string]string `json:"seriesLabels"`
+		Exemplars    []struct {
+			Labels    map[string]string `json:"labels"`
+			Value     string            `json:"value"`
+			Timestamp float64           `json:"timestamp"`
+		} `json:"exemplars"`
+	}
+	if err := c.get("/api/v1/query_exemplars", params, &data); err != nil {
+		return nil, err
+	}
+
+	result := make([]promExemplarSeries, 0, len(data))
+	for _, d := range data {
+		series := promExemplarSeries{Labels: d.SeriesLabels}
+		for _, e := range d.Exemplars {
+			value, err := strconv.ParseFloat(e.Value, 64)
+			if err != nil {
+				return nil, err
+			}
+			series.Exemplars = append(series.Exemplars, promExemplar{
+				Labels:    e.Labels,
+				Value:     value,
+				Timestamp: int64(math.Round(e.Timestamp * 1000)),
+			})
+		}
+		result = append(result, series)
+	}
+	return result, nil
+}
+
+// parseSamplePair parses a [<unix seconds>, <value>] pair, the value being a string for float samples
+// and an object for native histograms.
+func parseSamplePair(pair []json.RawMessage, histogram bool) (promSample, error) {
+	if len(pair) != 2 {
+		return promSample{}, fmt.Errorf("malformed sample %s", pair)
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(pair[0], &seconds); err != nil {
+		return promSample{}, err
+	}
+	sample := promSample{
+		Timestamp: int64(math.Round(seconds * 1000)),
+		Histogram: histogram,
+	}
+	if histogram {
+		return sample, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(pair[1], &value); err != nil {
+		return promSample{}, err
+	}
+	var err error
+	sample.Value, err = strconv.ParseFloat(value, 64)
+	return sample, err
+}
+
+func formatAPITime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
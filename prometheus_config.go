@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// PrometheusConfig models the parts of the Prometheus config the scenarios vary. It is read from the
+// config of the prometheus section of a scenario file with the same field names as the Prometheus
+// config file, and rendered to the config file by YAML. Durations use the Prometheus format, e.g. 15s.
+type PrometheusConfig struct {
+	Global        PrometheusGlobalConfig `yaml:"global"`
+	ScrapeConfigs []ScrapeConfig         `yaml:"scrape_configs,omitempty"`
+	RemoteWrite   []RemoteWriteConfig    `yaml:"remote_write,omitempty"`
+	RemoteRead    []RemoteReadConfig     `yaml:"remote_read,omitempty"`
+	Storage       *StorageConfig         `yaml:"storage,omitempty"`
+	// OTLP configures the OTLP receiver, only supported by recent Prometheus versions.
+	OTLP *OTLPConfig `yaml:"otlp,omitempty"`
+}
+
+// PrometheusGlobalConfig holds the global settings and the defaults of all scrape configs.
+type PrometheusGlobalConfig struct {
+	ScrapeInterval     model.Duration    `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout      model.Duration    `yaml:"scrape_timeout,omitempty"`
+	EvaluationInterval model.Duration    `yaml:"evaluation_interval,omitempty"`
+	ExternalLabels     map[string]string `yaml:"external_labels,omitempty"`
+}
+
+// ScrapeConfig is a scrape job of static targets.
+type ScrapeConfig struct {
+	JobName        string         `yaml:"job_name"`
+	ScrapeInterval model.Duration `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout  model.Duration `yaml:"scrape_timeout,omitempty"`
+	MetricsPath    string         `yaml:"metrics_path,omitempty"`
+	Scheme         string         `yaml:"scheme,omitempty"`
+	HonorLabels    bool           `yaml:"honor_labels,omitempty"`
+	StaticConfigs  []StaticConfig `yaml:"static_configs,omitempty"`
+}
+
+// StaticConfig is a group of targets sharing labels.
+type StaticConfig struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+}
+
+// RemoteWriteConfig is a remote_write block.
+type RemoteWriteConfig struct {
+	URL                  string            `yaml:"url"`
+	Name                 string            `yaml:"name,omitempty"`
+	RemoteTimeout        model.Duration    `yaml:"remote_timeout,omitempty"`
+	Headers              map[string]string `yaml:"headers,omitempty"`
+	SendExemplars        bool              `yaml:"send_exemplars,omitempty"`
+	SendNativeHistograms bool              `yaml:"send_native_histograms,omitempty"`
+	QueueConfig          *QueueConfig      `yaml:"queue_config,omitempty"`
+}
+
+// QueueConfig tunes the shards of a remote_write block.
+type QueueConfig struct {
+	Capacity          int            `yaml:"capacity,omitempty"`
+	MaxShards         int            `yaml:"max_shards,omitempty"`
+	MinShards         int            `yaml:"min_shards,omitempty"`
+	MaxSamplesPerSend int            `yaml:"max_samples_per_send,omitempty"`
+	BatchSendDeadline model.Duration `yaml:"batch_send_deadline,omitempty"`
+}
+
+// RemoteReadConfig is a remote_read block.
+type RemoteReadConfig struct {
+	URL           string            `yaml:"url"`
+	Name          string            `yaml:"name,omitempty"`
+	RemoteTimeout model.Duration    `yaml:"remote_timeout,omitempty"`
+	Headers       map[string]string `yaml:"headers,omitempty"`
+	ReadRecent    bool              `yaml:"read_recent,omitempty"`
+}
+
+// StorageConfig is the storage section.
+type StorageConfig struct {
+	TSDB      *TSDBConfig      `yaml:"tsdb,omitempty"`
+	Exemplars *ExemplarsConfig `yaml:"exemplars,omitempty"`
+}
+
+// TSDBConfig holds the TSDB settings of the config file, the others are command line flags.
+type TSDBConfig struct {
+	// OutOfOrderTimeWindow is how old out-of-order samples may be to still be appended.
+	OutOfOrderTimeWindow model.Duration `yaml:"out_of_order_time_window,omitempty"`
+}
+
+// ExemplarsConfig sizes the exemplar storage, enabled by --enable-feature=exemplar-storage.
+type ExemplarsConfig struct {
+	// MaxExemplars is the size of the circular buffer of exemplars shared by all series, 100000 by default.
+	MaxExemplars int64 `yaml:"max_exemplars,omitempty"`
+}
+
+// OTLPConfig is the otlp section.
+type OTLPConfig struct {
+	// PromoteResourceAttributes are the resource attributes added as labels to every series of the resource.
+	PromoteResourceAttributes []string `yaml:"promote_resource_attributes,omitempty"`
+}
+
+// selfScrapeJob is the name of the scrape job of Prometheus itself.
+const selfScrapeJob = "prometheus"
+
+// NewPrometheusConfig creates the default config of the Prometheus listening on port: a 15 seconds scrape
+// and evaluation interval and a job scraping Prometheus itself.
+func NewPrometheusConfig(port int) *PrometheusConfig {
+	return &PrometheusConfig{
+		Global: PrometheusGlobalConfig{
+			ScrapeInterval:     model.Duration(15 * time.Second),
+			EvaluationInterval: model.Duration(15 * time.Second),
+		},
+		ScrapeConfigs: []ScrapeConfig{selfScrapeConfig(port)},
+	}
+}
+
+func selfScrapeConfig(port int) ScrapeConfig {
+	return ScrapeConfig{
+		JobName:       selfScrapeJob,
+		StaticConfigs: []StaticConfig{{Targets: []string{fmt.Sprintf("localhost:%d", port)}}},
+	}
+}
+
+// newPrometheusConfig creates the config of the scenario from the config of the spec. Unset global
+// intervals keep their defaults and the self-scrape job is added unless the spec defines a job of
+// the same name.
+func newPrometheusConfig(spec PrometheusSpec) *PrometheusConfig {
+	config := NewPrometheusConfig(spec.Port)
+	custom := spec.Config
+
+	if custom.Global.ScrapeInterval != 0 {
+		config.Global.ScrapeInterval = custom.Global.ScrapeInterval
+	}
+	if custom.Global.EvaluationInterval != 0 {
+		config.Global.EvaluationInterval = custom.Global.EvaluationInterval
+	}
+	config.Global.ScrapeTimeout = custom.Global.ScrapeTimeout
+	config.Global.ExternalLabels = custom.Global.ExternalLabels
+
+	for _, sc := range custom.ScrapeConfigs {
+		if sc.JobName == selfScrapeJob {
+			config.ScrapeConfigs = config.ScrapeConfigs[:0]
+			break
+		}
+	}
+	for _, sc := range custom.ScrapeConfigs {
+		config.AddScrapeConfig(sc)
+	}
+	for _, rw := range custom.RemoteWrite {
+		config.AddRemoteWrite(rw)
+	}
+	for _, rr := range custom.RemoteRead {
+		config.AddRemoteRead(rr)
+	}
+	if custom.Storage != nil && custom.Storage.TSDB != nil {
+		config.SetOutOfOrderTimeWindow(time.Duration(custom.Storage.TSDB.OutOfOrderTimeWindow))
+	}
+	if custom.Storage != nil && custom.Storage.Exemplars != nil {
+		config.SetMaxExemplars(custom.Storage.Exemplars.MaxExemplars)
+	}
+	if custom.OTLP != nil {
+		config.PromoteResourceAttributes(custom.OTLP.PromoteResourceAttributes...)
+	}
+	return config
+}
+
+// AddScrapeConfig adds a scrape job.
+func (c *PrometheusConfig) AddScrapeConfig(sc ScrapeConfig) *PrometheusConfig {
+	c.ScrapeConfigs = append(c.ScrapeConfigs, sc)
+	return c
+}
+
+// AddRemoteWrite adds a remote_write block.
+func (c *PrometheusConfig) AddRemoteWrite(rw RemoteWriteConfig) *PrometheusConfig {
+	c.RemoteWrite = append(c.RemoteWrite, rw)
+	return c
+}
+
+// AddRemoteRead adds a remote_read block.
+func (c *PrometheusConfig) AddRemoteRead(rr RemoteReadConfig) *PrometheusConfig {
+	c.RemoteRead = append(c.RemoteRead, rr)
+	return c
+}
+
+// SetOutOfOrderTimeWindow sets storage.tsdb.out_of_order_time_window, zero disables out-of-order ingestion.
+func (c *PrometheusConfig) SetOutOfOrderTimeWindow(window time.Duration) *PrometheusConfig {
+	if window == 0 {
+		return c.updateStorage(func(s *StorageConfig) { s.TSDB = nil })
+	}
+	return c.updateStorage(func(s *StorageConfig) {
+		s.TSDB = &TSDBConfig{OutOfOrderTimeWindow: model.Duration(window)}
+	})
+}
+
+// SetMaxExemplars sets storage.exemplars.max_exemplars, zero keeps the default of Prometheus.
+func (c *PrometheusConfig) SetMaxExemplars(n int64) *PrometheusConfig {
+	if n == 0 {
+		return c.updateStorage(func(s *StorageConfig) { s.Exemplars = nil })
+	}
+	return c.updateStorage(func(s *StorageConfig) { s.Exemplars = &ExemplarsConfig{MaxExemplars: n} })
+}
+
+// updateStorage applies update to the storage section and removes the section once it is empty.
+func (c *PrometheusConfig) updateStorage(update func(s *StorageConfig)) *PrometheusConfig {
+	if c.Storage == nil {
+		c.Storage = &StorageConfig{}
+	}
+	update(c.Storage)
+	if c.Storage.TSDB == nil && c.Storage.Exemplars == nil {
+		c.Storage = nil
+	}
+	return c
+}
+
+// PromoteResourceAttributes sets otlp.promote_resource_attributes, no attributes removes the otlp section.
+func (c *PrometheusConfig) PromoteResourceAttributes(attributes ...string) *PrometheusConfig {
+	if len(attributes) == 0 {
+		c.OTLP = nil
+		return c
+	}
+	c.OTLP = &OTLPConfig{PromoteResourceAttributes: attributes}
+	return c
+}
+
+// YAML renders the config file.
+func (c *PrometheusConfig) YAML() (string, error) {
+	return encodeYAML(c)
+}
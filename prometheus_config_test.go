@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewPrometheusConfig(t *testing.T) {
+	var spec PrometheusSpec
+	doc := `
+port: 9090
+config:
+  global:
+    scrape_interval: 5s
+    external_labels:
+      cluster: bench
+  remote_write:
+    - url: http://localhost:9201/write
+      queue_config:
+        max_shards: 4
+  remote_read:
+    - url: http://localhost:9201/read
+      read_recent: true
+  storage:
+    tsdb:
+      out_of_order_time_window: 30m
+    exemplars:
+      max_exemplars: 500000
+  otlp:
+    promote_resource_attributes: [service.name, k8s.pod.name]
+`
+	decoder := yaml.NewDecoder(strings.NewReader(doc))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := newPrometheusConfig(spec).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed PrometheusConfig
+	if err = yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("invalid YAML %q: %s", out, err)
+	}
+
+	if parsed.Global.ScrapeInterval != model.Duration(5*time.Second) || parsed.Global.EvaluationInterval != model.Duration(15*time.Second) {
+		t.Errorf("got global %+v", parsed.Global)
+	}
+	if !strings.Contains(out, "scrape_interval: 5s") {
+		t.Errorf("durations not rendered in the Prometheus format:\n%s", out)
+	}
+	if len(parsed.ScrapeConfigs) != 1 || parsed.ScrapeConfigs[0].StaticConfigs[0].Targets[0] != "localhost:9090" {
+		t.Errorf("got scrape configs %+v, want the self-scrape job", parsed.ScrapeConfigs)
+	}
+	if len(parsed.RemoteWrite) != 1 || parsed.RemoteWrite[0].QueueConfig.MaxShards != 4 {
+		t.Errorf("got remote_write %+v", parsed.RemoteWrite)
+	}
+	if len(parsed.RemoteRead) != 1 || !parsed.RemoteRead[0].ReadRecent {
+		t.Errorf("got remote_read %+v", parsed.RemoteRead)
+	}
+	if parsed.Storage.TSDB.OutOfOrderTimeWindow != model.Duration(30*time.Minute) || parsed.Storage.Exemplars.MaxExemplars != 500000 {
+		t.Errorf("got storage %+v %+v", parsed.Storage.TSDB, parsed.Storage.Exemplars)
+	}
+	if strings.Join(parsed.OTLP.PromoteResourceAttributes, ",") != "service.name,k8s.pod.name" {
+		t.Errorf("got otlp %+v", parsed.OTLP)
+	}
+}
+
+func TestNewPrometheusConfigDefault(t *testing.T) {
+	out, err := newPrometheusConfig(DefaultScenarioSpec().Prometheus).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sections not supported by all Prometheus versions must be left out by default.
+	for _, section := range []string{"remote_write", "remote_read", "storage", "otlp"} {
+		if strings.Contains(out, section) {
+			t.Errorf("default config contains %s:\n%s", section, out)
+		}
+	}
+
+	// Disabling one storage setting keeps the other, the section is removed once both are unset.
+	config := NewPrometheusConfig(9090).SetOutOfOrderTimeWindow(time.Hour).SetMaxExemplars(10)
+	if config.SetOutOfOrderTimeWindow(0); config.Storage == nil || config.Storage.Exemplars.MaxExemplars != 10 {
+		t.Errorf("got storage %+v", config.Storage)
+	}
+	if config.SetMaxExemplars(0); config.Storage != nil {
+		t.Errorf("got storage %+v, want none", config.Storage)
+	}
+
+	// A job named like the self-scrape job replaces it.
+	spec := DefaultScenarioSpec().Prometheus
+	spec.Config.ScrapeConfigs = []ScrapeConfig{{JobName: "prometheus", MetricsPath: "/federate"}}
+	config = newPrometheusConfig(spec)
+	if len(config.ScrapeConfigs) != 1 || config.ScrapeConfigs[0].MetricsPath != "/federate" {
+		t.Errorf("got scrape configs %+v", config.ScrapeConfigs)
+	}
+}
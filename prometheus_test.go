@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+func TestFlagValue(t *testing.T) {
+	for _, tc := range []struct {
+		args  []string
+		value string
+		ok    bool
+	}{
+		{args: []string{"--web.listen-address=:9090", "--storage.tsdb.path=/tmp/data"}, value: "/tmp/data", ok: true},
+		{args: []string{"--storage.tsdb.path", "/tmp/data", "--web.enable-lifecycle"}, value: "/tmp/data", ok: true},
+		{args: []string{"--storage.tsdb.path.retention=1d", "--storage.tsdb.path"}},
+		{args: nil},
+	} {
+		value, ok := flagValue(tc.args, "--storage.tsdb.path")
+		if value != tc.value || ok != tc.ok {
+			t.Errorf("flagValue(%q) = %q, %t, want %q, %t", tc.args, value, ok, tc.value, tc.ok)
+		}
+	}
+}
+
+func TestPrometheusRunnerRestart(t *testing.T) {
+	dir := t.TempDir()
+	exePath := filepath.Join(dir, "prometheus")
+	if err := os.WriteFile(exePath, []byte("#!/bin/sh\ntrap 'exit 0' TERM\necho ready\nwhile :; do sleep 0.1; done\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewPrometheusRunner(WithAgentExePath(exePath))
+	params := testbed.StartParams{Name: "Prometheus", LogFilePath: filepath.Join(dir, "prometheus.log"), CmdArgs: []string{"--config.file", "none"}}
+
+	for i := 0; i < 2; i++ {
+		if err := runner.Start(params); err != nil {
+			t.Fatalf("start %d: %s", i, err)
+		}
+		if err := runner.Start(params); err == nil {
+			t.Errorf("start %d: started twice", i)
+		}
+		// Wait for the trap, SIGTERM fails the process until then.
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if out, _ := os.ReadFile(params.LogFilePath); len(out) > 0 {
+				break
+			}
+		}
+		if stopped, err := runner.Stop(); !stopped || err != nil {
+			t.Fatalf("stop %d: stopped %t, %v", i, stopped, err)
+		}
+		if stopped, _ := runner.Stop(); stopped {
+			t.Errorf("stop %d: stopped twice", i)
+		}
+	}
+}
+
+// TestPrometheusRunnerRestartMonitored restarts a monitored process while its resources are sampled,
+// run it with -race.
+func TestPrometheusRunnerRestartMonitored(t *testing.T) {
+	dir := t.TempDir()
+	exePath := filepath.Join(dir, "prometheus")
+	if err := os.WriteFile(exePath, []byte("#!/bin/sh\ntrap 'exit 0' TERM\necho ready\nwhile :; do sleep 0.01; done\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewPrometheusRunner(WithAgentExePath(exePath))
+	params := testbed.StartParams{Name: "Prometheus", LogFilePath: filepath.Join(dir, "prometheus.log"), CmdArgs: []string{"--config.file", "none"}}
+	params.SetResourceSpec(&testbed.ResourceSpec{ExpectedMaxCPU: 1000, ExpectedMaxRAM: 100000, ResourceCheckPeriod: 10 * time.Millisecond})
+
+	sampler := NewResourceSampler("prometheus", runner, 10*time.Millisecond)
+	sampler.Start()
+	for i := 0; i < 2; i++ {
+		if err := runner.Start(params); err != nil {
+			t.Fatalf("start %d: %s", i, err)
+		}
+		watched := make(chan error)
+		go func() {
+			watched <- runner.WatchResourceConsumption()
+		}()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if out, _ := os.ReadFile(params.LogFilePath); len(out) > 0 && runner.GetProcessMon() != nil {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		_ = runner.GetTotalConsumption()
+		if _, err := runner.Stop(); err != nil {
+			t.Fatalf("stop %d: %s", i, err)
+		}
+		if err := <-watched; err != nil {
+			t.Errorf("watch %d: %s", i, err)
+		}
+	}
+	sampler.Stop()
+
+	if len(sampler.Samples()) == 0 {
+		t.Error("got no samples of the monitored processes")
+	}
+	if rc := runner.GetTotalConsumption(); rc.RAMMiBMax == 0 {
+		t.Errorf("got %+v, want the consumption of both processes", rc)
+	}
+}
+
+func TestPrometheusRunnerTotalConsumptionRestart(t *testing.T) {
+	runner := NewPrometheusRunner()
+	start := time.Now()
+
+	// A process using 1s of CPU in 10s, stopped and followed by one using 3s in 10s.
+	runner.startTime, runner.lastElapsedTime = start, start.Add(10*time.Second)
+	runner.lastProcessTimes = &cpu.TimesStat{User: 0.5, System: 0.5}
+	runner.cpuPercentMax, runner.ramMiBMax = 50, 200
+	runner.accumulateCPUUsage()
+	runner.startTime, runner.lastElapsedTime = start.Add(20*time.Second), start.Add(30*time.Second)
+	runner.lastProcessTimes = &cpu.TimesStat{User: 3}
+
+	rc := runner.GetTotalConsumption()
+	if rc.CPUPercentAvg != 20 || rc.CPUPercentMax != 50 || rc.RAMMiBMax != 200 {
+		t.Errorf("got %+v, want an average CPU of 20%% over both processes and their maximums", rc)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// resourceCheckMetric is the gauge sent with the resource attributes, stored as resource_check.
+const resourceCheckMetric = "resource.check"
+
+// identifyingResourceAttributes make the job and instance labels, they are neither promoted nor
+// added to target_info.
+var identifyingResourceAttributes = map[string]string{
+	"service.name":        "checkout",
+	"service.namespace":   "shop",
+	"service.instance.id": "checkout-7d9f8-x2k4q",
+}
+
+// checkedResourceAttributes are the resource attributes whose mapping to labels is checked, the ones
+// PromQL joins with target_info usually pull in.
+var checkedResourceAttributes = map[string]string{
+	"k8s.pod.name":           "checkout-7d9f8-x2k4q",
+	"k8s.namespace.name":     "shop",
+	"k8s.node.name":          "node-1",
+	"host.name":              "bench-host",
+	"deployment.environment": "benchmark",
+	"cloud.region":           "eu-west-1",
+}
+
+// defaultPromotedResourceAttributes are promoted by the native OTLP variant unless the scenario sets
+// otlp.promote_resource_attributes in the Prometheus config.
+var defaultPromotedResourceAttributes = []string{"k8s.pod.name", "deployment.environment"}
+
+// resourceVariant is one way of getting resource attributes to Prometheus.
+type resourceVariant struct {
+	Name string
+	Mode IngestionMode
+	// Promote is otlp.promote_resource_attributes of the Prometheus config.
+	Promote []string
+	// ResourceToTelemetry enables resource_to_telemetry_conversion of the prometheusremotewrite exporter.
+	ResourceToTelemetry bool
+}
+
+// resourceVariants returns the variants compared: native OTLP without and with promoted attributes
+// and remote write without and with resource_to_telemetry_conversion.
+func resourceVariants(promote []string) []resourceVariant {
+	if len(promote) == 0 {
+		promote = defaultPromotedResourceAttributes
+	}
+	return []resourceVariant{
+		{Name: "otlp-native", Mode: ModeOTLPNative},
+		{Name: "otlp-native-promoted", Mode: ModeOTLPNative, Promote: promote},
+		{Name: "remote-write", Mode: ModeRemoteWrite},
+		{Name: "remote-write-converted", Mode: ModeRemoteWrite, ResourceToTelemetry: true},
+	}
+}
+
+// wantLabel returns true if the variant should add the resource attribute as label to every series.
+func (v resourceVariant) wantLabel(attribute string) bool {
+	return v.ResourceToTelemetry || containsToken(v.Promote, attribute)
+}
+
+// ResourceAttributeResult tells where a resource attribute ended up with one variant.
+type ResourceAttributeResult struct {
+	Attribute string `json:"attribute"`
+	Label     string `json:"label"`
+	// WantLabel and GotLabel tell whether the label is expected and found on the series of the metric,
+	// GotTargetInfo whether it is found on target_info, where it is always expected.
+	WantLabel     bool `json:"want_label"`
+	GotLabel      bool `json:"got_label"`
+	GotTargetInfo bool `json:"got_target_info"`
+	Pass          bool `json:"pass"`
+}
+
+// where returns where the attribute was found, "label", "target_info", both or "missing".
+func (r ResourceAttributeResult) where() string {
+	var places []string
+	if r.GotLabel {
+		places = append(places, "label")
+	}
+	if r.GotTargetInfo {
+		places = append(places, "target_info")
+	}
+	if len(places) == 0 {
+		return "missing"
+	}
+	return strings.Join(places, "+")
+}
+
+// ResourceAttributesReport holds the results of every variant.
+type ResourceAttributesReport struct {
+	Variants []string                             `json:"variants"`
+	Results  map[string][]ResourceAttributeResult `json:"results"`
+	// Series are the labels of the stored metric and target_info series by variant.
+	Series map[string][]string `json:"series"`
+}
+
+// Failed returns the number of attributes not found where expected, over all variants.
+func (r *ResourceAttributesReport) Failed() int {
+	failed := 0
+	for _, results := range r.Results {
+		for _, result := range results {
+			if !result.Pass {
+				failed++
+			}
+		}
+	}
+	return failed
+}
+
+// resourceCheckMetrics returns one gauge data point at ts with the identifying, checked and extra
+// resource attributes.
+func resourceCheckMetrics(extra map[string]string, ts time.Time) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	for _, attrs := range []map[string]string{extra, identifyingResourceAttributes, checkedResourceAttributes} {
+		for k, v := range attrs {
+			rm.Resource().Attributes().PutStr(k, v)
+		}
+	}
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName(resourceCheckMetric)
+	dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	dp.SetDoubleValue(1)
+	return md
+}
+
+// resourceJob is the job label derived from identifyingResourceAttributes.
+func resourceJob() string {
+	return identifyingResourceAttributes["service.namespace"] + "/" + identifyingResourceAttributes["service.name"]
+}
+
+// checkResourceAttributes compares the labels of the stored metric and target_info series with what
+// the variant should produce. Both series must be found, nil results mean they are not stored yet.
+func checkResourceAttributes(variant resourceVariant, metricLabels, targetInfoLabels map[string]string) []ResourceAttributeResult {
+	if metricLabels == nil || targetInfoLabels == nil {
+		return nil
+	}
+	attributes := make([]string, 0, len(checkedResourceAttributes))
+	for attribute := range checkedResourceAttributes {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+
+	results := make([]ResourceAttributeResult, 0, len(attributes))
+	for _, attribute := range attributes {
+		value := checkedResourceAttributes[attribute]
+		result := ResourceAttributeResult{
+			Attribute: attribute,
+			Label:     promLabelName(attribute),
+			WantLabel: variant.wantLabel(attribute),
+		}
+		result.GotLabel = metricLabels[result.Label] == value
+		result.GotTargetInfo = targetInfoLabels[result.Label] == value
+		result.Pass = result.GotLabel == result.WantLabel && result.GotTargetInfo
+		results = append(results, result)
+	}
+	return results
+}
+
+// runResourceAttributes sends a metric with rich resource attributes through every variant and checks
+// which attributes became labels of the metric and which only live in target_info. The report is logged
+// and written to resource_attributes.md and resource_attributes.json in results/<name>/resource_attributes.
+func runResourceAttributes(spec ScenarioSpec) (*ResourceAttributesReport, error) {
+	var promote []string
+	if spec.Prometheus.Config.OTLP != nil {
+		promote = spec.Prometheus.Config.OTLP.PromoteResourceAttributes
+	}
+	resultDir := path.Join("results", spec.Name, "resource_attributes")
+	resultsSummary := &ScenarioResults{}
+	resultsSummary.Init(resultDir)
+
+	report := &ResourceAttributesReport{Results: map[string][]ResourceAttributeResult{}, Series: map[string][]string{}}
+	for _, variant := range resourceVariants(promote) {
+		cell := spec
+		cell.Matrix = MatrixSpec{}
+		cell.Mode = variant.Mode
+		cell.ValidateData = false
+		cell.Name = path.Join(spec.Name, "resource_attributes", variant.Name)
+		cell.Collector.ResourceToTelemetry = variant.ResourceToTelemetry
+		// Applied by newPrometheusConfig through PrometheusConfig.PromoteResourceAttributes.
+		cell.Prometheus.Config.OTLP = nil
+		if len(variant.Promote) > 0 {
+			cell.Prometheus.Config.OTLP = &OTLPConfig{PromoteResourceAttributes: variant.Promote}
+		}
+
+		start := time.Now()
+		var results []ResourceAttributeResult
+		var series []string
+		err := sendAndCheck(cell, resultsSummary, []pmetric.Metrics{resourceCheckMetrics(spec.Load.Attributes, start)},
+			func(client *PrometheusClient) (bool, error) {
+				metricLabels, targetInfoLabels, err := queryResourceSeries(client, start)
+				if err != nil {
+					return false, err
+				}
+				series = nil
+				for _, labels := range []map[string]string{metricLabels, targetInfoLabels} {
+					if labels != nil {
+						series = append(series, seriesKey(labels["__name__"], labels, nil))
+					}
+				}
+				results = checkResourceAttributes(variant, metricLabels, targetInfoLabels)
+				return results != nil, nil
+			})
+		if err != nil {
+			return nil, fmt.Errorf("resource attributes of %s: %w", variant.Name, err)
+		}
+		if results == nil {
+			// Nothing stored, every attribute is missing.
+			results = checkResourceAttributes(variant, map[string]string{}, map[string]string{})
+		}
+		report.Variants = append(report.Variants, variant.Name)
+		report.Results[variant.Name] = results
+		report.Series[variant.Name] = series
+	}
+	resultsSummary.Save()
+
+	var table strings.Builder
+	writeResourceAttributesTable(&table, report)
+	log.Printf("Resource attributes of %s:\n%s", spec.Name, table.String())
+	if err := os.WriteFile(path.Join(resultDir, "resource_attributes.md"), []byte(table.String()), 0644); err != nil {
+		return report, err
+	}
+	return report, writeJSONFile(path.Join(resultDir, "resource_attributes.json"), report)
+}
+
+// queryResourceSeries returns the labels of the stored resource_check and target_info series of the
+// resource, nil for a series not stored.
+func queryResourceSeries(client *PrometheusClient, start time.Time) (map[string]string, map[string]string, error) {
+	name := promMetricName(resourceCheckMetrics(nil, start).ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0))
+	var found []map[string]string
+	for _, metric := range []string{name, "target_info"} {
+		stored, err := client.Series([]string{fmt.Sprintf("%s{job=%q}", metric, resourceJob())}, start.Add(-time.Minute), time.Now().Add(time.Minute))
+		if err != nil {
+			return nil, nil, err
+		}
+		var labels map[string]string
+		if len(stored) > 0 {
+			labels = stored[0]
+		}
+		found = append(found, labels)
+	}
+	return found[0], found[1], nil
+}
+
+// writeResourceAttributesTable writes one markdown table row per attribute telling where every variant
+// stored it, followed by the stored series.
+func writeResourceAttributesTable(w io.Writer, report *ResourceAttributesReport) {
+	fmt.Fprint(w, "Attribute|Label")
+	for _, variant := range report.Variants {
+		fmt.Fprintf(w, "|%s", variant)
+	}
+	fmt.Fprint(w, "\n---------|-----")
+	for range report.Variants {
+		fmt.Fprint(w, "|----")
+	}
+	fmt.Fprintln(w)
+
+	if len(report.Variants) == 0 {
+		return
+	}
+	for i, result := range report.Results[report.Variants[0]] {
+		fmt.Fprintf(w, "%s|%s", result.Attribute, result.Label)
+		for _, variant := range report.Variants {
+			r := report.Results[variant][i]
+			if r.Pass {
+				fmt.Fprintf(w, "|%s", r.where())
+			} else {
+				fmt.Fprintf(w, "|FAIL: %s", r.where())
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprint(w, "\nStored series:\n\n")
+	for _, variant := range report.Variants {
+		for _, series := range report.Series[variant] {
+			fmt.Fprintf(w, "- %s: `%s`\n", variant, series)
+		}
+	}
+}
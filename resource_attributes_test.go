@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// storedLabels returns the labels of the resource attributes as Prometheus stores them, with job
+// and instance.
+func storedLabels(name string, attributes ...string) map[string]string {
+	labels := map[string]string{"__name__": name, "job": resourceJob(), "instance": identifyingResourceAttributes["service.instance.id"]}
+	for _, attribute := range attributes {
+		labels[promLabelName(attribute)] = checkedResourceAttributes[attribute]
+	}
+	return labels
+}
+
+func TestCheckResourceAttributes(t *testing.T) {
+	all := make([]string, 0, len(checkedResourceAttributes))
+	for attribute := range checkedResourceAttributes {
+		all = append(all, attribute)
+	}
+	targetInfo := storedLabels("target_info", all...)
+	variants := resourceVariants(nil)
+
+	for _, tc := range []struct {
+		variant resourceVariant
+		metric  map[string]string
+		failed  []string
+	}{
+		{variant: variants[0], metric: storedLabels("resource_check")},
+		{variant: variants[1], metric: storedLabels("resource_check", "k8s.pod.name", "deployment.environment")},
+		// Promoted attributes not stored as labels fail.
+		{variant: variants[1], metric: storedLabels("resource_check", "k8s.pod.name"), failed: []string{"deployment.environment"}},
+		{variant: variants[3], metric: storedLabels("resource_check", all...)},
+		// Attributes stored as labels without being converted fail.
+		{variant: variants[2], metric: storedLabels("resource_check", "host.name"), failed: []string{"host.name"}},
+	} {
+		results := checkResourceAttributes(tc.variant, tc.metric, targetInfo)
+		if len(results) != len(checkedResourceAttributes) {
+			t.Fatalf("%s: got %d results", tc.variant.Name, len(results))
+		}
+		var failed []string
+		for _, result := range results {
+			if !result.Pass {
+				failed = append(failed, result.Attribute)
+			}
+		}
+		if strings.Join(failed, ",") != strings.Join(tc.failed, ",") {
+			t.Errorf("%s: got failed %v, want %v", tc.variant.Name, failed, tc.failed)
+		}
+	}
+
+	// Attributes missing from target_info fail whatever the variant.
+	results := checkResourceAttributes(variants[0], storedLabels("resource_check"), storedLabels("target_info"))
+	if results[0].Pass || results[0].where() != "missing" {
+		t.Errorf("got %+v, want missing", results[0])
+	}
+	if checkResourceAttributes(variants[0], nil, targetInfo) != nil {
+		t.Errorf("got results before the metric was stored")
+	}
+}
+
+func TestResourceCheckMetrics(t *testing.T) {
+	md := resourceCheckMetrics(map[string]string{"team": "bench"}, time.Now())
+	attrs := md.ResourceMetrics().At(0).Resource().Attributes()
+	if attrs.Len() != len(identifyingResourceAttributes)+len(checkedResourceAttributes)+1 {
+		t.Errorf("got resource %v", attrs.AsRaw())
+	}
+	if name := promMetricName(md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)); name != "resource_check" {
+		t.Errorf("got name %s", name)
+	}
+}
+
+func TestResourceAttributesTable(t *testing.T) {
+	variants := resourceVariants([]string{"host.name"})
+	report := &ResourceAttributesReport{Results: map[string][]ResourceAttributeResult{}}
+	for _, variant := range variants[:2] {
+		report.Variants = append(report.Variants, variant.Name)
+		report.Results[variant.Name] = checkResourceAttributes(variant, storedLabels("resource_check", "host.name"), storedLabels("target_info", "host.name"))
+	}
+
+	var table strings.Builder
+	writeResourceAttributesTable(&table, report)
+	if !strings.Contains(table.String(), "host.name|host_name|FAIL: label+target_info|label+target_info\n") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+	if report.Failed() != 2*(len(checkedResourceAttributes)-1)+1 {
+		t.Errorf("got %d failed", report.Failed())
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// ResourceSample is one measurement of the resource usage of a process.
+type ResourceSample struct {
+	Time       time.Time
+	CPUPercent float64
+	RSSBytes   uint64
+	Threads    int32
+	OpenFDs    int32
+	// ReadBytes and WriteBytes are the cumulative storage I/O of the process.
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+// ResourceSampler periodically samples the process monitored by a runner and keeps every sample, so
+// that the shape of the resource usage over time can be analyzed, e.g. warm-up, GC sawtooth and head
+// compaction spikes. The runners only keep the current, maximum and average values.
+type ResourceSampler struct {
+	// Descriptive name of the sampled process.
+	name   string
+	runner testbed.OtelcolRunner
+	period time.Duration
+
+	startOnce  sync.Once
+	stopOnce   sync.Once
+	doneSignal chan struct{}
+	stopped    chan struct{}
+
+	mutex   sync.Mutex
+	samples []ResourceSample
+
+	// Pid and process times of the last sample, to compute the CPU usage between two samples.
+	lastPid          int32
+	lastElapsedTime  time.Time
+	lastProcessTimes *cpu.TimesStat
+}
+
+// NewResourceSampler creates a sampler of the process monitored by runner, sampling every period.
+func NewResourceSampler(name string, runner testbed.OtelcolRunner, period time.Duration) *ResourceSampler {
+	return &ResourceSampler{
+		name:       name,
+		runner:     runner,
+		period:     period,
+		doneSignal: make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Start starts sampling in the background. Samples are only taken while the runner monitors a
+// running process, so the sampler can be started before the process and keeps sampling across restarts.
+func (s *ResourceSampler) Start() {
+	s.startOnce.Do(func() {
+		go s.run()
+	})
+}
+
+// Stop stops sampling and waits for the sampling goroutine to finish.
+func (s *ResourceSampler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.doneSignal)
+	})
+	// A sampler that was never started must not start after Stop.
+	s.startOnce.Do(func() {
+		close(s.stopped)
+	})
+	<-s.stopped
+}
+
+func (s *ResourceSampler) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sampleOnce()
+		case <-s.doneSignal:
+			return
+		}
+	}
+}
+
+func (s *ResourceSampler) sampleOnce() {
+	proc := s.runner.GetProcessMon()
+	if proc == nil {
+		// Not started yet or resource monitoring is disabled.
+		return
+	}
+	if running, err := proc.IsRunning(); err != nil || !running {
+		return
+	}
+
+	now := time.Now()
+	times, err := proc.Times()
+	if err != nil {
+		return
+	}
+	mi, err := proc.MemoryInfo()
+	if err != nil {
+		return
+	}
+	sample := ResourceSample{
+		Time:     now,
+		RSSBytes: mi.RSS,
+	}
+
+	// The CPU usage is only known from the second sample of the same process on.
+	if proc.Pid == s.lastPid && s.lastProcessTimes != nil {
+		deltaElapsedTime := now.Sub(s.lastElapsedTime).Seconds()
+		deltaCPUTime := totalCPU(times) - totalCPU(s.lastProcessTimes)
+		if deltaElapsedTime > 0 && deltaCPUTime > 0 {
+			sample.CPUPercent = deltaCPUTime * 100 / deltaElapsedTime
+		}
+	}
+	s.lastPid = proc.Pid
+	s.lastElapsedTime = now
+	s.lastProcessTimes = times
+
+	// Thread, file descriptor and I/O counts are not available on all platforms, they stay 0 then.
+	if threads, err := proc.NumThreads(); err == nil {
+		sample.Threads = threads
+	}
+	if fds, err := proc.NumFDs(); err == nil {
+		sample.OpenFDs = fds
+	}
+	if io, err := proc.IOCounters(); err == nil {
+		sample.ReadBytes = io.ReadBytes
+		sample.WriteBytes = io.WriteBytes
+	}
+
+	s.mutex.Lock()
+	s.samples = append(s.samples, sample)
+	s.mutex.Unlock()
+}
+
+// Samples returns all samples taken so far.
+func (s *ResourceSampler) Samples() []ResourceSample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]ResourceSample(nil), s.samples...)
+}
+
+// writeResourceUsageCSV writes the samples of all samplers to fileName, one row per sample, with the
+// time both as RFC 3339 timestamp and as seconds since start.
+func writeResourceUsageCSV(fileName string, start time.Time, samplers ...*ResourceSampler) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	_ = w.Write([]string{"process", "timestamp", "elapsed_seconds", "cpu_percent", "rss_bytes", "threads", "open_fds", "read_bytes", "write_bytes"})
+	for _, sampler := range samplers {
+		for _, sample := range sampler.Samples() {
+			_ = w.Write([]string{
+				sampler.name,
+				sample.Time.UTC().Format(time.RFC3339Nano),
+				strconv.FormatFloat(sample.Time.Sub(start).Seconds(), 'f', 3, 64),
+				strconv.FormatFloat(sample.CPUPercent, 'f', 2, 64),
+				strconv.FormatUint(sample.RSSBytes, 10),
+				strconv.FormatInt(int64(sample.Threads), 10),
+				strconv.FormatInt(int64(sample.OpenFDs), 10),
+				strconv.FormatUint(sample.ReadBytes, 10),
+				strconv.FormatUint(sample.WriteBytes, 10),
+			})
+		}
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
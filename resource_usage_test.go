@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// processRunner is an OtelcolRunner monitoring the given process, only GetProcessMon is implemented.
+type processRunner struct {
+	testbed.OtelcolRunner
+	proc *process.Process
+}
+
+func (r *processRunner) GetProcessMon() *process.Process {
+	return r.proc
+}
+
+func TestResourceSampler(t *testing.T) {
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	sampler := NewResourceSampler("test", &processRunner{proc: proc}, 10*time.Millisecond)
+	idle := NewResourceSampler("idle", &processRunner{}, 10*time.Millisecond)
+	sampler.Start()
+	idle.Start()
+	time.Sleep(100 * time.Millisecond)
+	sampler.Stop()
+	idle.Stop()
+
+	samples := sampler.Samples()
+	if len(samples) < 2 {
+		t.Fatalf("got %d samples, want at least 2", len(samples))
+	}
+	for _, sample := range samples {
+		if sample.RSSBytes == 0 {
+			t.Errorf("sample at %s has no RSS", sample.Time)
+		}
+	}
+	if len(idle.Samples()) != 0 {
+		t.Errorf("got %d samples without process, want 0", len(idle.Samples()))
+	}
+
+	fileName := filepath.Join(t.TempDir(), "resource_usage.csv")
+	if err = writeResourceUsageCSV(fileName, start, sampler, idle); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(samples)+1 {
+		t.Fatalf("got %d records, want header and %d samples", len(records), len(samples))
+	}
+	if records[0][0] != "process" || records[1][0] != "test" {
+		t.Errorf("unexpected records %v", records[:2])
+	}
+}
+
+func TestResourceSamplerStopWithoutStart(t *testing.T) {
+	sampler := NewResourceSampler("test", &processRunner{}, time.Second)
+	sampler.Stop()
+	sampler.Start()
+	if len(sampler.Samples()) != 0 {
+		t.Error("sampler started after Stop")
+	}
+}
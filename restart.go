@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RestartSpec stops Prometheus partway through the load and starts it again on the same TSDB, to see
+// what the exporters do with the data sent while it is down.
+type RestartSpec struct {
+	// After is the time since the load started at which Prometheus is stopped, zero disables the restart.
+	After time.Duration `yaml:"after"`
+	// Downtime is how long Prometheus stays stopped before it is started again.
+	Downtime time.Duration `yaml:"downtime"`
+}
+
+// Enabled returns true if Prometheus is restarted during the load.
+func (r RestartSpec) Enabled() bool {
+	return r.After > 0
+}
+
+// Validate checks that Prometheus is up again before the end of a load lasting duration, zero for the
+// default duration.
+func (r RestartSpec) Validate(duration time.Duration) error {
+	if r.After < 0 || r.Downtime < 0 {
+		return errors.New("prometheus restart after and downtime must not be negative")
+	}
+	if !r.Enabled() {
+		return nil
+	}
+	if duration == 0 {
+		var err error
+		if duration, err = defaultDuration(); err != nil {
+			return err
+		}
+	}
+	if r.After+r.Downtime >= duration {
+		return fmt.Errorf("prometheus restart after %s for %s does not end within the load duration %s", r.After, r.Downtime, duration)
+	}
+	return nil
+}
+
+// prometheusRestart holds what RestartPrometheus and MeasureRestart observed.
+type prometheusRestart struct {
+	stoppedAt time.Time
+	readyAt   time.Time
+	// recovery is the time from starting Prometheus again until it was ready, mostly WAL replay.
+	recovery time.Duration
+	// itemsBefore and itemsAfter are the items sent by the load generator when Prometheus was stopped
+	// and when it was ready again.
+	itemsBefore uint64
+	itemsAfter  uint64
+	// storedSamples is the number of samples of the load generator Prometheus stored, negative if unknown.
+	storedSamples float64
+}
+
+// RestartReport compares the data sent during a load interrupted by a restart of Prometheus with the
+// data Prometheus stored in the end.
+type RestartReport struct {
+	Exporter string `json:"exporter"`
+	// DowntimeSeconds is the time from stopping Prometheus until it was ready again, RecoverySeconds
+	// the part of it from starting Prometheus again until it was ready.
+	DowntimeSeconds float64 `json:"downtime_seconds"`
+	RecoverySeconds float64 `json:"recovery_seconds"`
+	// ItemsSent are the data points generated during the whole load, ItemsSentDuringDowntime those
+	// generated while Prometheus was not ready.
+	ItemsSent               uint64 `json:"items_sent"`
+	ItemsSentDuringDowntime uint64 `json:"items_sent_during_downtime"`
+	// ExporterSentPoints and ExporterFailedPoints are the points the collector exporter to Prometheus
+	// delivered and dropped, after retries or because its queue was full.
+	ExporterSentPoints   float64 `json:"exporter_sent_points"`
+	ExporterFailedPoints float64 `json:"exporter_failed_points"`
+	// RetriedRequests counts the requests the exporter helper logged as failed and retried, -1 if unknown:
+	// the log is missing or the exporter retries without the exporter helper, as prometheusremotewrite.
+	RetriedRequests int `json:"retried_requests"`
+	// StoredSamples is the number of samples of the load generator returned by a query after the run,
+	// negative if the query failed. Every data point of a gauge or sum is one sample.
+	StoredSamples float64 `json:"stored_samples"`
+	// OutOfOrderRejected is prometheus_tsdb_out_of_order_samples_total of both Prometheus processes: the
+	// samples rejected for being older than the newest sample of their series, e.g. retried after newer
+	// ones were stored. A sample delivered again with the same timestamp and value is dropped silently
+	// by the TSDB, such duplicates cannot be observed.
+	OutOfOrderRejected float64 `json:"out_of_order_rejected"`
+	// Unavailable lists the measures of a restart that cannot be taken and why: the duplicated samples
+	// always, the retried requests if unknown.
+	Unavailable []string `json:"unavailable"`
+}
+
+const (
+	unavailableDuplicates = "duplicated samples: Prometheus drops a sample delivered again with the same timestamp and value without counting it"
+	unavailableRetries    = "retried requests: %s retries without logging them"
+)
+
+// LostSamples returns the data points sent but not stored, zero if the stored samples are unknown.
+func (r *RestartReport) LostSamples() float64 {
+	if r.StoredSamples < 0 || r.StoredSamples >= float64(r.ItemsSent) {
+		return 0
+	}
+	return float64(r.ItemsSent) - r.StoredSamples
+}
+
+func (r *RestartReport) String() string {
+	retried := "unknown"
+	if r.RetriedRequests >= 0 {
+		retried = strconv.Itoa(r.RetriedRequests)
+	}
+	return fmt.Sprintf("%s downtime:%.1fs recovery:%.1fs sent:%d (%d during downtime) exporter sent:%.0f failed:%.0f retried requests:%s stored:%.0f lost:%.0f out of order rejected:%.0f unavailable: %s",
+		r.Exporter, r.DowntimeSeconds, r.RecoverySeconds, r.ItemsSent, r.ItemsSentDuringDowntime,
+		r.ExporterSentPoints, r.ExporterFailedPoints, retried, r.StoredSamples, r.LostSamples(), r.OutOfOrderRejected,
+		strings.Join(r.Unavailable, "; "))
+}
+
+// newRestartReport builds the report of restart from the final collector exporter stats, the samples
+// scraped from Prometheus before and after the restart and the retries logged to agentLog.
+func newRestartReport(restart *prometheusRestart, itemsSent uint64, exporters []ExporterStats, exporter string,
+	promSamples []ScrapedSample, agentLog string) *RestartReport {
+	report := &RestartReport{
+		Exporter:           exporter,
+		RecoverySeconds:    restart.recovery.Seconds(),
+		ItemsSent:          itemsSent,
+		StoredSamples:      restart.storedSamples,
+		OutOfOrderRejected: counterTotal(promSamples, "prometheus_tsdb_out_of_order_samples_total"),
+		Unavailable:        []string{unavailableDuplicates},
+	}
+	if !restart.readyAt.IsZero() {
+		report.DowntimeSeconds = restart.readyAt.Sub(restart.stoppedAt).Seconds()
+		report.ItemsSentDuringDowntime = restart.itemsAfter - restart.itemsBefore
+	}
+	for _, stats := range exporters {
+		if stats.Exporter == exporter {
+			report.ExporterSentPoints = stats.SentPoints
+			report.ExporterFailedPoints = stats.SendFailedPoints + stats.EnqueueFailedPoints
+		}
+	}
+	report.RetriedRequests = -1
+	if !logsRetries(exporter) {
+		report.Unavailable = append(report.Unavailable, fmt.Sprintf(unavailableRetries, exporter))
+	} else if retried, err := countRetriedRequests(agentLog, exporter); err == nil {
+		report.RetriedRequests = retried
+	} else {
+		report.Unavailable = append(report.Unavailable, "retried requests: "+err.Error())
+	}
+	return report
+}
+
+// logsRetries returns true if the exporter retries failed requests through the exporter helper, which
+// logs them. The prometheusremotewrite exporter disables its retries and retries in its own HTTP client
+// without logging, its retries are unknown.
+func logsRetries(exporter string) bool {
+	typ, _, _ := strings.Cut(exporter, "/")
+	return typ != "prometheusremotewrite"
+}
+
+// counterTotal sums the increase of the counter name over the samples of all its series, see counterTotals.
+func counterTotal(samples []ScrapedSample, name string) float64 {
+	var total float64
+	for _, value := range counterTotals(samples, func(sample ScrapedSample) bool { return sample.Name == name }) {
+		total += value
+	}
+	return total
+}
+
+// retryLogMessage is logged by the exporter helper of the collector for every request it retries.
+const retryLogMessage = "Exporting failed. Will retry the request after interval."
+
+// countRetriedRequests counts the lines of the collector log fileName reporting a retried request of exporter.
+func countRetriedRequests(fileName string, exporter string) (int, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	name := fmt.Sprintf(`"name": %q`, exporter)
+	retried := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, retryLogMessage) && strings.Contains(line, name) {
+			retried++
+		}
+	}
+	return retried, scanner.Err()
+}
+
+// loadGeneratorNames matches the names of the series of all data providers.
+const loadGeneratorNames = "load_generator_.+"
+
+// storedSamplesQuery counts the samples of the load generator stored since start, evaluated at end.
+func storedSamplesQuery(start, end time.Time) string {
+	window := end.Sub(start).Truncate(time.Second) + time.Minute
+	return fmt.Sprintf(`sum(count_over_time({__name__=~%q}[%ds]))`, loadGeneratorNames, int64(window.Seconds()))
+}
+
+// countStoredSamples returns the number of samples of the load generator Prometheus stored since start.
+// Retried data may still be queued by the exporter when the load stops, so the count is repeated every
+// second until it stops growing, for up to timeout.
+func countStoredSamples(client *PrometheusClient, start time.Time, timeout time.Duration) (float64, error) {
+	deadline := time.Now().Add(timeout)
+	stored := -1.0
+	for {
+		now := time.Now()
+		series, err := client.Query(storedSamplesQuery(start, now), now)
+		if err != nil {
+			return 0, err
+		}
+		count := 0.0
+		if len(series) > 0 && len(series[0].Samples) > 0 {
+			count = series[0].Samples[0].Value
+		}
+		if count == stored || now.After(deadline) {
+			return count, nil
+		}
+		stored = count
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterTotal(t *testing.T) {
+	samples := []ScrapedSample{
+		{Name: "prometheus_tsdb_out_of_order_samples_total", Series: `a{type="float"}`, Value: 2},
+		{Name: "prometheus_tsdb_head_series", Series: "b", Value: 100},
+		{Name: "prometheus_tsdb_out_of_order_samples_total", Series: `a{type="float"}`, Value: 5},
+		{Name: "prometheus_tsdb_out_of_order_samples_total", Series: `a{type="histogram"}`, Value: 1},
+		// Prometheus restarted, its counters start from zero.
+		{Name: "prometheus_tsdb_out_of_order_samples_total", Series: `a{type="float"}`, Value: 3},
+		{Name: "prometheus_tsdb_out_of_order_samples_total", Series: `a{type="float"}`, Value: 4},
+	}
+	if total := counterTotal(samples, "prometheus_tsdb_out_of_order_samples_total"); total != 10 {
+		t.Errorf("got total %g, want 10", total)
+	}
+}
+
+func TestNewRestartReport(t *testing.T) {
+	agentLog := filepath.Join(t.TempDir(), "agent.log")
+	retry := "2023-09-20T10:00:00.000Z\tinfo\texporterhelper/queued_retry.go:423\t" + retryLogMessage + "\t"
+	content := retry + `{"kind": "exporter", "data_type": "metrics", "name": "otlphttp/prometheus", "error": "connection refused", "interval": "5.2s"}` + "\n" +
+		retry + `{"kind": "exporter", "data_type": "metrics", "name": "otlphttp", "error": "connection refused", "interval": "4.1s"}` + "\n" +
+		retry + `{"kind": "exporter", "data_type": "metrics", "name": "otlphttp/prometheus", "error": "503", "interval": "7.5s"}` + "\n"
+	if err := os.WriteFile(agentLog, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stoppedAt := time.Now()
+	restart := &prometheusRestart{
+		stoppedAt:     stoppedAt,
+		readyAt:       stoppedAt.Add(12 * time.Second),
+		recovery:      2 * time.Second,
+		itemsBefore:   20000,
+		itemsAfter:    32000,
+		storedSamples: 55000,
+	}
+	exporters := []ExporterStats{{Exporter: "otlphttp/prometheus", SentPoints: 58000, SendFailedPoints: 1000, EnqueueFailedPoints: 1000}}
+	promSamples := []ScrapedSample{{Name: "prometheus_tsdb_out_of_order_samples_total", Series: "a", Value: 300}}
+
+	report := newRestartReport(restart, 60000, exporters, "otlphttp/prometheus", promSamples, agentLog)
+	if report.DowntimeSeconds != 12 || report.RecoverySeconds != 2 || report.ItemsSentDuringDowntime != 12000 {
+		t.Errorf("unexpected downtime %+v", report)
+	}
+	if report.ExporterSentPoints != 58000 || report.ExporterFailedPoints != 2000 || report.RetriedRequests != 2 {
+		t.Errorf("unexpected exporter stats %+v", report)
+	}
+	if report.LostSamples() != 5000 || report.OutOfOrderRejected != 300 {
+		t.Errorf("got %g lost, %g rejected as out of order", report.LostSamples(), report.OutOfOrderRejected)
+	}
+
+	if len(report.Unavailable) != 1 || !strings.HasPrefix(report.Unavailable[0], "duplicated samples") {
+		t.Errorf("got unavailable %q, want the duplicated samples", report.Unavailable)
+	}
+	report = newRestartReport(restart, 60000, nil, "prometheusremotewrite", nil, agentLog)
+	if report.RetriedRequests != -1 || len(report.Unavailable) != 2 || !strings.Contains(report.String(), "retried requests:unknown") {
+		t.Errorf("got %d retried requests of prometheusremotewrite, want -1 reported unavailable: %s", report.RetriedRequests, report)
+	}
+
+	restart.storedSamples = -1
+	if report := newRestartReport(restart, 60000, nil, "otlphttp/prometheus", nil, filepath.Join(t.TempDir(), "missing.log")); report.LostSamples() != 0 || report.RetriedRequests != -1 {
+		t.Errorf("got %g lost, %d retried requests without stored samples and log", report.LostSamples(), report.RetriedRequests)
+	}
+}
+
+func TestNewRestartReportPerf(t *testing.T) {
+	batches, generated := generatePerfBatches(3)
+
+	// The samples Prometheus stores of the perf provider are all counted by the stored samples query.
+	names := regexp.MustCompile("^(?:" + loadGeneratorNames + ")$")
+	stored := 0
+	for name, byKey := range newExpectedData(batches).series {
+		if !names.MatchString(name) {
+			t.Errorf("perf series %s is not counted as stored", name)
+			continue
+		}
+		for _, series := range byKey {
+			stored += len(series.samples)
+		}
+	}
+
+	restart := &prometheusRestart{storedSamples: float64(stored)}
+	report := newRestartReport(restart, generated, nil, "prometheusremotewrite", nil, filepath.Join(t.TempDir(), "agent.log"))
+	if report.LostSamples() != 0 {
+		t.Errorf("got %g lost of %d perf points all stored", report.LostSamples(), generated)
+	}
+}
+
+func TestCountStoredSamples(t *testing.T) {
+	counts := []string{"100", "150", "150"}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if query := r.URL.Query().Get("query"); !strings.HasPrefix(query, `sum(count_over_time({__name__=~"load_generator_.+"}[6`) {
+			t.Errorf("got query %s", query)
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,%q]}]}}`, counts[requests])
+		requests++
+	}))
+	defer server.Close()
+
+	client := &PrometheusClient{baseURL: server.URL, httpClient: server.Client()}
+	stored, err := countStoredSamples(client, time.Now().Add(-5*time.Second), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != 150 || requests != 3 {
+		t.Errorf("got %g stored after %d queries, want 150 once the count stopped growing", stored, requests)
+	}
+}
+
+func TestRestartSpecValidate(t *testing.T) {
+	// The default duration is 15s.
+	t.Setenv("TEST_DURATION", "")
+	for _, tc := range []struct {
+		restart  RestartSpec
+		duration time.Duration
+		valid    bool
+	}{
+		{restart: RestartSpec{}, valid: true},
+		{restart: RestartSpec{After: 20 * time.Second, Downtime: 10 * time.Second}, duration: time.Minute, valid: true},
+		{restart: RestartSpec{After: 5 * time.Second, Downtime: 5 * time.Second}, valid: true},
+		{restart: RestartSpec{After: time.Minute}, duration: time.Minute},
+		{restart: RestartSpec{After: 40 * time.Second, Downtime: 20 * time.Second}, duration: time.Minute},
+		{restart: RestartSpec{After: 10 * time.Second, Downtime: 5 * time.Second}},
+		{restart: RestartSpec{After: 20 * time.Second}},
+		{restart: RestartSpec{Downtime: -time.Second}},
+	} {
+		if err := tc.restart.Validate(tc.duration); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v, %s) = %v, want valid %t", tc.restart, tc.duration, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+)
+
+// ScenarioValidator defines the interface for validating and reporting scenario results. It is the
+// Scenario counterpart of testbed.TestCaseValidator, which only accepts a *testbed.TestCase.
+type ScenarioValidator interface {
+	// Validate executes validation routines and records an error on mismatches.
+	Validate(scenario *Scenario)
+	// RecordResults updates the TestResultsSummary of the scenario with the results of the scenario.
+	RecordResults(scenario *Scenario)
+}
+
+// PerfScenarioValidator implements ScenarioValidator for scenarios using ScenarioResults for summarizing results.
+type PerfScenarioValidator struct{}
+
+func (v *PerfScenarioValidator) Validate(scenario *Scenario) {
+	sent := scenario.LoadGenerator.DataItemsSent()
+	received := scenario.MockBackend.DataItemsReceived()
+	if sent != received {
+		scenario.indicateError(fmt.Errorf("Received and sent counters do not match: sent %d, received %d", sent, received))
+		return
+	}
+	log.Printf("Sent and received data matches.")
+}
+
+func (v *PerfScenarioValidator) RecordResults(scenario *Scenario) {
+	scenario.resultsSummary.Add(scenario.name, scenario.Result())
+}
+
+// benchmarkResult holds the results of a benchmark to be stored by benchmark-action. See
+// https://github.com/benchmark-action/github-action-benchmark#examples for more details on the
+// format
+type benchmarkResult struct {
+	Name  string  `json:"name"`
+	Unit  string  `json:"unit"`
+	Value float64 `json:"value"`
+	Range string  `json:"range,omitempty"`
+	Extra string  `json:"extra,omitempty"`
+}
+
+// ScenarioResults implements the testbed.TestResultsSummary interface in the format of
+// testbed.PerformanceResults, with the resource consumption of both the collector and Prometheus.
+// testbed.PerformanceResults cannot be used as its result type can only be built inside the testbed package.
+type ScenarioResults struct {
+	resultsDir       string
+	resultsFile      *os.File
+	benchmarkResults []*benchmarkResult
+	totalDuration    time.Duration
+}
+
+func (r *ScenarioResults) Init(resultsDir string) {
+	r.resultsDir = resultsDir
+	r.benchmarkResults = []*benchmarkResult{}
+
+	// Create resultsSummary file
+	if err := os.MkdirAll(resultsDir, os.FileMode(0755)); err != nil {
+		log.Fatal(err)
+	}
+	var err error
+	r.resultsFile, err = os.Create(path.Join(r.resultsDir, "TESTRESULTS.md"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Write the header
+	_, _ = io.WriteString(r.resultsFile,
+		"# Test PerformanceResults\n"+
+			fmt.Sprintf("Started: %s\n\n", time.Now().Format(time.RFC1123Z))+
+			"Test                                    |Result|Duration|Col CPU Avg%|Col CPU Max%|Col RAM Avg MiB|Col RAM Max MiB|Prom CPU Avg%|Prom CPU Max%|Prom RAM Avg MiB|Prom RAM Max MiB|Sent Items|Received Items|\n"+
+			"----------------------------------------|------|-------:|-----------:|-----------:|--------------:|--------------:|------------:|------------:|---------------:|---------------:|---------:|-------------:|\n")
+}
+
+// Save the total results and close the file.
+func (r *ScenarioResults) Save() {
+	if r.resultsFile == nil {
+		return
+	}
+	_, _ = io.WriteString(r.resultsFile,
+		fmt.Sprintf("\nTotal duration: %.0fs\n", r.totalDuration.Seconds()))
+	r.resultsFile.Close()
+	r.saveBenchmarks()
+}
+
+// Add results for one scenario.
+func (r *ScenarioResults) Add(_ string, result interface{}) {
+	scenarioResult, ok := result.(*ScenarioResult)
+	if !ok || r.resultsFile == nil {
+		return
+	}
+
+	status := "PASS"
+	if scenarioResult.ErrorCause != "" {
+		status = "FAIL"
+	}
+
+	_, _ = io.WriteString(r.resultsFile,
+		fmt.Sprintf("%-40s|%-6s|%7.0fs|%12.1f|%12.1f|%15d|%15d|%13.1f|%13.1f|%16d|%16d|%10d|%14d|%s\n",
+			scenarioResult.Name,
+			status,
+			scenarioResult.Duration.Seconds(),
+			scenarioResult.Agent.CPUPercentAvg,
+			scenarioResult.Agent.CPUPercentMax,
+			scenarioResult.Agent.RAMMiBAvg,
+			scenarioResult.Agent.RAMMiBMax,
+			scenarioResult.Prometheus.CPUPercentAvg,
+			scenarioResult.Prometheus.CPUPercentMax,
+			scenarioResult.Prometheus.RAMMiBAvg,
+			scenarioResult.Prometheus.RAMMiBMax,
+			scenarioResult.ItemsSent,
+			scenarioResult.ItemsReceived,
+			scenarioResult.ErrorCause,
+		),
+	)
+	r.totalDuration += scenarioResult.Duration
+
+	// individual benchmark results
+	r.addConsumptionBenchmarks(scenarioResult.Name+" - Collector", scenarioResult.Agent)
+	r.addConsumptionBenchmarks(scenarioResult.Name+" - Prometheus", scenarioResult.Prometheus)
+	r.benchmarkResults = append(r.benchmarkResults, &benchmarkResult{
+		Name:  "dropped_item_count",
+		Value: float64(scenarioResult.Dropped()),
+		Unit:  "items",
+		Extra: fmt.Sprintf("%s - Dropped Item Count", scenarioResult.Name),
+	})
+	r.benchmarkResults = append(r.benchmarkResults, &benchmarkResult{
+		Name:  "prometheus_ready_seconds",
+		Value: scenarioResult.PrometheusReadyLatency.Seconds(),
+		Unit:  "s",
+		Extra: fmt.Sprintf("%s - Prometheus Readiness Latency", scenarioResult.Name),
+	})
+	if scenarioResult.TSDB != nil {
+		r.benchmarkResults = append(r.benchmarkResults,
+			&benchmarkResult{
+				Name:  "tsdb_bytes_per_sample",
+				Value: scenarioResult.TSDB.BytesPerSample,
+				Unit:  "bytes",
+				Extra: fmt.Sprintf("%s - Prometheus TSDB Bytes per Sample", scenarioResult.Name),
+			},
+			&benchmarkResult{
+				Name:  "tsdb_size_mib",
+				Value: float64(scenarioResult.TSDB.TotalBytes) / mibibyte,
+				Unit:  "MiB",
+				Extra: fmt.Sprintf("%s - Prometheus TSDB Size", scenarioResult.Name),
+			},
+		)
+	}
+	if scenarioResult.OutOfOrder != nil {
+		r.benchmarkResults = append(r.benchmarkResults, &benchmarkResult{
+			Name:  "ooo_rejected_samples",
+			Value: scenarioResult.OutOfOrder.Rejected(),
+			Unit:  "samples",
+			Extra: fmt.Sprintf("%s - Prometheus Out-of-Order and Too Old Samples Rejected", scenarioResult.Name),
+		})
+	}
+	if scenarioResult.Exemplars != nil {
+		r.benchmarkResults = append(r.benchmarkResults, &benchmarkResult{
+			Name:  "exemplars_stored",
+			Value: float64(scenarioResult.Exemplars.Stored()),
+			Unit:  "exemplars",
+			Extra: fmt.Sprintf("%s - Prometheus Exemplars Stored", scenarioResult.Name),
+		})
+	}
+	if scenarioResult.Restart != nil {
+		r.benchmarkResults = append(r.benchmarkResults, &benchmarkResult{
+			Name:  "restart_lost_samples",
+			Value: scenarioResult.Restart.LostSamples(),
+			Unit:  "samples",
+			Extra: fmt.Sprintf("%s - Samples Lost During Prometheus Restart", scenarioResult.Name),
+		})
+	}
+	for _, exporter := range scenarioResult.Exporters {
+		r.benchmarkResults = append(r.benchmarkResults, &benchmarkResult{
+			Name:  "exporter_send_failed_points",
+			Value: exporter.SendFailedPoints + exporter.EnqueueFailedPoints,
+			Unit:  "points",
+			Extra: fmt.Sprintf("%s - Collector Exporter %s Failed Points", scenarioResult.Name, exporter.Exporter),
+		})
+	}
+}
+
+func (r *ScenarioResults) addConsumptionBenchmarks(processName string, rc *testbed.ResourceConsumption) {
+	cpuChartName := fmt.Sprintf("%s - Cpu Percentage", processName)
+	memoryChartName := fmt.Sprintf("%s - RAM (MiB)", processName)
+
+	r.benchmarkResults = append(r.benchmarkResults,
+		&benchmarkResult{Name: "cpu_percentage_avg", Value: rc.CPUPercentAvg, Unit: "%", Extra: cpuChartName},
+		&benchmarkResult{Name: "cpu_percentage_max", Value: rc.CPUPercentMax, Unit: "%", Extra: cpuChartName},
+		&benchmarkResult{Name: "ram_mib_avg", Value: float64(rc.RAMMiBAvg), Unit: "MiB", Extra: memoryChartName},
+		&benchmarkResult{Name: "ram_mib_max", Value: float64(rc.RAMMiBMax), Unit: "MiB", Extra: memoryChartName},
+	)
+}
+
+// saveBenchmarks writes benchmarks to file as json to be stored by
+// benchmark-action
+func (r *ScenarioResults) saveBenchmarks() {
+	path := path.Join(r.resultsDir, "benchmarks.json")
+	j, _ := json.MarshalIndent(r.benchmarkResults, "", "  ")
+	_ = os.WriteFile(path, j, 0600)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+)
+
+func TestScenarioResultsRow(t *testing.T) {
+	dir := t.TempDir()
+	results := &ScenarioResults{}
+	results.Init(dir)
+	results.Add("", &ScenarioResult{
+		Name:          "remote-write",
+		Duration:      75 * time.Second,
+		Agent:         &testbed.ResourceConsumption{CPUPercentAvg: 10.5, CPUPercentMax: 20, RAMMiBAvg: 100, RAMMiBMax: 120},
+		Prometheus:    &testbed.ResourceConsumption{CPUPercentAvg: 5, CPUPercentMax: 8.5, RAMMiBAvg: 300, RAMMiBMax: 350},
+		ItemsSent:     1000,
+		ItemsReceived: 1000,
+	})
+	results.Add("", &ScenarioResult{
+		Name:       "otlp-native",
+		Duration:   15 * time.Second,
+		Agent:      &testbed.ResourceConsumption{},
+		Prometheus: &testbed.ResourceConsumption{},
+		ErrorCause: "Prometheus did not become ready",
+	})
+	results.Save()
+
+	out, err := os.ReadFile(filepath.Join(dir, "TESTRESULTS.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"remote-write                            |PASS  |     75s|        10.5|        20.0|            100|            120|          5.0|          8.5|             300|             350|      1000|          1000|\n",
+		"otlp-native                             |FAIL  |     15s|",
+		"Total duration: 90s\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("%q not found in:\n%s", want, out)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
 	"time"
 
 	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
@@ -34,7 +35,7 @@ type Scenario struct {
 
 	LoadGenerator *testbed.LoadGenerator
 	MockBackend   *testbed.MockBackend
-	validator     testbed.TestCaseValidator
+	validator     ScenarioValidator
 
 	startTime time.Time
 
@@ -42,13 +43,215 @@ type Scenario struct {
 	// failure or exceeding resource consumption, etc. The actual error message is already
 	// logged, this is only an indicator on which you can wait to be informed.
 	errorSignal chan struct{}
-	// Duration is the requested duration of the tests. Configured via TESTBED_DURATION
-	// env variable and defaults to 15 seconds if env variable is unspecified.
-	Duration   time.Duration
-	doneSignal chan struct{}
-	errorCause string
+	errorOnce   sync.Once
+	// Duration is the requested duration of the tests. Configured via WithDuration or the
+	// TEST_DURATION env variable and defaults to 15 seconds if neither is specified.
+	Duration       time.Duration
+	doneSignal     chan struct{}
+	errorCause     string
+	resultsSummary testbed.TestResultsSummary
+
+	// Port Prometheus listens on.
+	promPort int
+	// Maximum time to wait for Prometheus to be ready after start.
+	promReadyTimeout time.Duration
+	// Time it took Prometheus to be ready after start.
+	promReadyLatency time.Duration
+	// TSDB directory of Prometheus, "prometheus-data" in the result directory unless given by
+	// --storage.tsdb.path. keepPromData keeps it after the run for inspection, e.g. with promtool.
+	// userPromDataDir is set if the directory was given, it is never removed then.
+	promDataDir     string
+	keepPromData    bool
+	userPromDataDir bool
+	// promArgs are the arguments Prometheus was started with, reused by RestartPrometheus.
+	promArgs []string
+	// promRestart is set by RestartPrometheus, nil unless Prometheus was restarted.
+	promRestart *prometheusRestart
+	// tsdbFootprint is the size of the TSDB measured after Prometheus stopped, analyzeTSDB also runs
+	// "promtool tsdb analyze" on it.
+	tsdbFootprint *TSDBFootprint
+	analyzeTSDB   bool
+
+	// Interval of the resource usage time series of the agent and Prometheus.
+	resourceSamplePeriod time.Duration
+	agentSampler         *ResourceSampler
+	promSampler          *ResourceSampler
+	// Scraper of the Prometheus self-metrics, see prometheusSelfMetrics.
+	promScraper *MetricsScraper
+	// Port of the collector's internal metrics endpoint.
+	collectorTelemetryPort int
+	// Scraper of the collector's internal telemetry, see collectorSelfMetrics.
+	agentScraper *MetricsScraper
+
+	// spec the scenario was built from, set by NewScenarioFromSpec.
+	spec ScenarioSpec
+	// configCleanups remove the config files prepared for the processes, run by Stop.
+	configCleanups []func()
+
+	// recorder keeps the generated data for ValidatePrometheusData, nil if validation is disabled.
+	recorder *recordingDataProvider
+	// dataValidation is the report of ValidatePrometheusData.
+	dataValidation *DataValidationReport
+	// outOfOrder shifts the timestamps of the generated data, nil unless enabled by the load spec.
+	outOfOrder *outOfOrderDataProvider
+	// histograms is the histogram provider counting the exemplars attached, nil for other providers,
+	// and exemplars the report of ValidateExemplars.
+	histograms *histogramDataProvider
+	exemplars  *ExemplarReport
+	// histogramQueries holds the results of QueryHistograms.
+	histogramQueries []HistogramQueryResult
+
+	// Time the load was started and stopped.
+	loadStartTime time.Time
+	loadStopTime  time.Time
 }
 
+// ScenarioResult holds the measurements of one scenario run.
+type ScenarioResult struct {
+	Name string
+	Mode IngestionMode
+	Load testbed.LoadOptions
+	// StartTime is the time the scenario was created.
+	StartTime time.Time
+	// Duration is the time since the scenario was created.
+	Duration time.Duration
+	// LoadDuration is the time between starting and stopping the load.
+	LoadDuration  time.Duration
+	ItemsSent     uint64
+	ItemsReceived uint64
+	Agent         *testbed.ResourceConsumption
+	Prometheus    *testbed.ResourceConsumption
+	// PrometheusReadyLatency is the time from starting Prometheus until it was ready.
+	PrometheusReadyLatency time.Duration
+	// PrometheusMetrics holds the last scraped value of the Prometheus self-metrics by series.
+	PrometheusMetrics map[string]float64
+	// Exporters and Receivers hold the final point counters of the collector components.
+	Exporters []ExporterStats
+	Receivers []ReceiverStats
+	// TSDB is the footprint of the data stored by Prometheus, nil until Prometheus stopped.
+	TSDB *TSDBFootprint
+	// DataValidation is nil unless the data stored by Prometheus was validated.
+	DataValidation *DataValidationReport
+	// OutOfOrder is nil unless the data was sent late or out of order.
+	OutOfOrder *OutOfOrderReport
+	// Exemplars is nil unless the exemplars stored by Prometheus were validated.
+	Exemplars *ExemplarReport
+	// HistogramQueries holds the results of the histogram queries, nil unless the histogram provider
+	// generated classic or exponential histograms.
+	HistogramQueries []HistogramQueryResult
+	// Restart is nil unless Prometheus was restarted during the load.
+	Restart    *RestartReport
+	ErrorCause string
+}
+
+// newFailedScenarioResult returns the result of a scenario of spec that failed with err before running.
+func newFailedScenarioResult(spec ScenarioSpec, err error) *ScenarioResult {
+	return &ScenarioResult{
+		Name:       spec.Name,
+		Mode:       spec.Mode,
+		Load:       spec.Load.options(),
+		StartTime:  time.Now(),
+		Agent:      &testbed.ResourceConsumption{},
+		Prometheus: &testbed.ResourceConsumption{},
+		ErrorCause: err.Error(),
+	}
+}
+
+// Dropped returns the number of items sent but not received by the mock backend.
+func (r *ScenarioResult) Dropped() uint64 {
+	if r.ItemsReceived >= r.ItemsSent {
+		return 0
+	}
+	return r.ItemsSent - r.ItemsReceived
+}
+
+// PrometheusDropped returns the points the collector exporter to Prometheus failed to send or to queue.
+func (r *ScenarioResult) PrometheusDropped() float64 {
+	exporter := prometheusExporterName(r.Mode)
+	var dropped float64
+	for _, stats := range r.Exporters {
+		if stats.Exporter == exporter {
+			dropped += stats.SendFailedPoints + stats.EnqueueFailedPoints
+		}
+	}
+	return dropped
+}
+
+// Throughput returns the items received by the mock backend per second of load.
+func (r *ScenarioResult) Throughput() float64 {
+	if r.LoadDuration <= 0 {
+		return 0
+	}
+	return float64(r.ItemsReceived) / r.LoadDuration.Seconds()
+}
+
+// ScenarioOption defines a Scenario option.
+type ScenarioOption func(*Scenario)
+
+// defaultDuration returns the test case duration requested by the TEST_DURATION env variable, 15 seconds
+// if it is not set.
+func defaultDuration() (time.Duration, error) {
+	duration := os.Getenv("TEST_DURATION")
+	if duration == "" {
+		return 15 * time.Second, nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TEST_DURATION: %v", duration)
+	}
+	return d, nil
+}
+
+// WithDuration overrides the duration otherwise taken from the TEST_DURATION env variable.
+func WithDuration(duration time.Duration) ScenarioOption {
+	return func(scenario *Scenario) {
+		scenario.Duration = duration
+	}
+}
+
+// WithPrometheusPort sets the port used to wait for Prometheus to start, PortPrometheus by default.
+func WithPrometheusPort(port int) ScenarioOption {
+	return func(scenario *Scenario) {
+		scenario.promPort = port
+	}
+}
+
+// WithPrometheusReadyTimeout sets the maximum time to wait for Prometheus to be ready, 1 minute by default.
+func WithPrometheusReadyTimeout(timeout time.Duration) ScenarioOption {
+	return func(scenario *Scenario) {
+		scenario.promReadyTimeout = timeout
+	}
+}
+
+// WithKeepPrometheusData keeps the TSDB directory of Prometheus after the run, it is removed by default.
+func WithKeepPrometheusData(keep bool) ScenarioOption {
+	return func(scenario *Scenario) {
+		scenario.keepPromData = keep
+	}
+}
+
+// WithTSDBAnalysis writes the output of "promtool tsdb analyze" on the TSDB of the run to "tsdb_analyze.txt".
+func WithTSDBAnalysis(analyze bool) ScenarioOption {
+	return func(scenario *Scenario) {
+		scenario.analyzeTSDB = analyze
+	}
+}
+
+// WithCollectorTelemetryPort sets the port of the collector's internal metrics endpoint, PortCollectorTelemetry by default.
+func WithCollectorTelemetryPort(port int) ScenarioOption {
+	return func(scenario *Scenario) {
+		scenario.collectorTelemetryPort = port
+	}
+}
+
+// WithResourceSamplePeriod sets the interval of the resource usage time series, 1 second by default.
+func WithResourceSamplePeriod(period time.Duration) ScenarioOption {
+	return func(scenario *Scenario) {
+		scenario.resourceSamplePeriod = period
+	}
+}
+
+// NewScenario creates a scenario and its results directory. Processes are started by the Start methods.
 func NewScenario(
 	name string,
 	dataProvider testbed.DataProvider,
@@ -56,48 +259,61 @@ func NewScenario(
 	receiver testbed.DataReceiver,
 	agentProc testbed.OtelcolRunner,
 	promRunner testbed.OtelcolRunner,
-	validator testbed.TestCaseValidator,
+	validator ScenarioValidator,
 	resultsSummary testbed.TestResultsSummary,
 	resourceSpec testbed.ResourceSpec,
-) *Scenario {
+	opts ...ScenarioOption,
+) (*Scenario, error) {
 	scenario := Scenario{
-		name:         name,
-		errorSignal:  make(chan struct{}),
-		doneSignal:   make(chan struct{}),
-		startTime:    time.Now(),
-		Sender:       sender,
-		receiver:     receiver,
-		agentProc:    agentProc,
-		promRunner:   promRunner,
-		resourceSpec: resourceSpec,
-	}
-
-	// Get requested test case duration from env variable.
-	duration := os.Getenv("TEST_DURATION")
-	if duration == "" {
-		duration = "15s"
+		name:             name,
+		errorSignal:      make(chan struct{}),
+		doneSignal:       make(chan struct{}),
+		startTime:        time.Now(),
+		Sender:           sender,
+		receiver:         receiver,
+		agentProc:        agentProc,
+		promRunner:       promRunner,
+		validator:        validator,
+		resultsSummary:   resultsSummary,
+		resourceSpec:     resourceSpec,
+		promPort:         PortPrometheus,
+		promReadyTimeout: time.Minute,
+
+		resourceSamplePeriod:   time.Second,
+		collectorTelemetryPort: PortCollectorTelemetry,
 	}
+
 	var err error
-	scenario.Duration, err = time.ParseDuration(duration)
+	scenario.Duration, err = defaultDuration()
 	if err != nil {
-		log.Fatalf("Invalid TEST_DURATION: %v. Expecting a valid duration string.", duration)
-		return nil
+		return nil, fmt.Errorf("%w, expecting a valid duration string", err)
+	}
+
+	// Apply all provided options.
+	for _, opt := range opts {
+		opt(&scenario)
 	}
 
 	// Prepare directory for results.
 	scenario.resultDir, err = filepath.Abs(path.Join("results", scenario.name))
 	if err != nil {
-		log.Fatalf("Cannot resolve %s: %s", scenario.name, err.Error())
-		return nil
+		return nil, fmt.Errorf("cannot resolve %s: %w", scenario.name, err)
 	}
 	err = os.MkdirAll(scenario.resultDir, os.ModePerm)
 	if err != nil {
-		log.Fatalf("Cannot create directory %s: %s", scenario.resultDir, err.Error())
-		return nil
+		return nil, fmt.Errorf("cannot create directory %s: %w", scenario.resultDir, err)
+	}
+
+	// Remove the TSDB left over by a previous run of the scenario, so that its data is not queried again.
+	scenario.promDataDir = scenario.composeTestResultFileName("prometheus-data")
+	if err = os.RemoveAll(scenario.promDataDir); err != nil {
+		return nil, fmt.Errorf("cannot remove %s: %w", scenario.promDataDir, err)
 	}
 
 	// Set default resource check period.
-	scenario.resourceSpec.ResourceCheckPeriod = 3 * time.Second
+	if scenario.resourceSpec.ResourceCheckPeriod == 0 {
+		scenario.resourceSpec.ResourceCheckPeriod = 3 * time.Second
+	}
 	if scenario.Duration < scenario.resourceSpec.ResourceCheckPeriod {
 		// Resource check period should not be longer than entire test duration.
 		scenario.resourceSpec.ResourceCheckPeriod = scenario.Duration
@@ -105,15 +321,21 @@ func NewScenario(
 
 	scenario.LoadGenerator, err = testbed.NewLoadGenerator(dataProvider, sender)
 	if err != nil {
-		log.Fatalf("Cannot create load generator: %s", err.Error())
-		return nil
+		return nil, fmt.Errorf("cannot create load generator: %w", err)
 	}
 
 	scenario.MockBackend = testbed.NewMockBackend(scenario.composeTestResultFileName("backend.log"), receiver)
 
+	scenario.agentSampler = NewResourceSampler("collector", agentProc, scenario.resourceSamplePeriod)
+	scenario.promSampler = NewResourceSampler("prometheus", promRunner, scenario.resourceSamplePeriod)
+	scenario.promScraper = NewMetricsScraper("prometheus", fmt.Sprintf("http://%s:%d/metrics", AddressLocalhost, scenario.promPort),
+		scenario.resourceSamplePeriod, prometheusSelfMetrics)
+	scenario.agentScraper = NewMetricsScraper("collector", fmt.Sprintf("http://%s:%d/metrics", AddressLocalhost, scenario.collectorTelemetryPort),
+		scenario.resourceSamplePeriod, collectorSelfMetrics)
+
 	go scenario.logStats()
 
-	return &scenario
+	return &scenario, nil
 }
 
 func (scenario *Scenario) logStats() {
@@ -138,7 +360,7 @@ func (scenario *Scenario) logStatsOnce() {
 		scenario.MockBackend.GetStats())
 }
 
-// Stop stops the load generator, the agent and the backend.
+// Stop stops the load generator, the agent, the backend and Prometheus and removes the Prometheus data.
 func (scenario *Scenario) Stop() {
 	// Stop monitoring the agent
 	close(scenario.doneSignal)
@@ -148,13 +370,83 @@ func (scenario *Scenario) Stop() {
 	scenario.StopAgent()
 	scenario.StopBackend()
 	scenario.StopPrometheus()
+	scenario.agentSampler.Stop()
+	scenario.promSampler.Stop()
+	scenario.RemovePrometheusData()
+
+	collectorSamples := scenario.agentScraper.LastSamples()
+	logCollectorStats(newReceiverStats(collectorSamples), newExporterStats(collectorSamples))
+	if report := scenario.outOfOrderReport(); report != nil {
+		log.Printf("Out-of-order %s", report)
+	}
+	if report := scenario.restartReport(); report != nil {
+		log.Printf("Restart %s", report)
+	}
+
+	for _, cleanup := range scenario.configCleanups {
+		cleanup()
+	}
 
 	if scenario.skipResults {
 		return
 	}
 
 	// Report test results
-	// scenario.validator.RecordResults(scenario)
+	scenario.validator.RecordResults(scenario)
+	scenario.writeSummary()
+	scenario.writeResourceUsage()
+	scenario.writeScrapedMetrics()
+}
+
+// writeResourceUsage writes the resource usage time series of the agent and Prometheus to
+// "resource_usage.csv" located in the test directory.
+func (scenario *Scenario) writeResourceUsage() {
+	fileName := scenario.composeTestResultFileName("resource_usage.csv")
+	if err := writeResourceUsageCSV(fileName, scenario.startTime, scenario.agentSampler, scenario.promSampler); err != nil {
+		log.Printf("Cannot write %s: %s", fileName, err.Error())
+		return
+	}
+	log.Printf("Resource usage written to %s", fileName)
+}
+
+// writeSummary writes the machine-readable summary of the run to "summary.json" located in the test directory.
+func (scenario *Scenario) writeSummary() {
+	summary := newRunSummary(scenario.Result(), scenario.spec)
+	fileName := scenario.composeTestResultFileName("summary.json")
+	if err := writeJSONFile(fileName, summary); err != nil {
+		log.Printf("Cannot write %s: %s", fileName, err.Error())
+		return
+	}
+	log.Printf("Summary written to %s", fileName)
+}
+
+// writeScrapedMetrics writes the Prometheus self-metrics and the collector's internal telemetry scraped
+// during the run to "prometheus_metrics.csv" and "collector_metrics.csv" located in the test directory.
+func (scenario *Scenario) writeScrapedMetrics() {
+	for fileName, scraper := range map[string]*MetricsScraper{
+		"prometheus_metrics.csv": scenario.promScraper,
+		"collector_metrics.csv":  scenario.agentScraper,
+	} {
+		fileName = scenario.composeTestResultFileName(fileName)
+		if err := writeScrapedMetricsCSV(fileName, scenario.startTime, scraper); err != nil {
+			log.Printf("Cannot write %s: %s", fileName, err.Error())
+			continue
+		}
+		log.Printf("%s metrics written to %s", scraper.name, fileName)
+	}
+}
+
+// ValidateData validates data received by mock backend against what was generated and sent to the collector
+// instance under test by the LoadGenerator.
+func (scenario *Scenario) ValidateData() {
+	select {
+	case <-scenario.errorSignal:
+		// Error is already signaled and recorded. Validating data is pointless.
+		return
+	default:
+	}
+
+	scenario.validator.Validate(scenario)
 }
 
 func (scenario *Scenario) composeTestResultFileName(fileName string) string {
@@ -170,10 +462,77 @@ func (scenario *Scenario) indicateError(err error) {
 	// Print to log for visibility
 	log.Print(err.Error())
 
-	scenario.errorCause = err.Error()
+	// Only the first error is the cause, later ones are usually its consequences.
+	scenario.errorOnce.Do(func() {
+		scenario.errorCause = err.Error()
+
+		// Signal the error via channel
+		close(scenario.errorSignal)
+	})
+}
+
+// Failed returns true if an error was indicated during the scenario execution.
+func (scenario *Scenario) Failed() bool {
+	select {
+	case <-scenario.errorSignal:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result returns the measurements of the scenario. Call it after stopping the load and the processes
+// to get the final resource consumption.
+func (scenario *Scenario) Result() *ScenarioResult {
+	result := &ScenarioResult{
+		Name:                   scenario.name,
+		Mode:                   scenario.spec.Mode,
+		Load:                   scenario.spec.Load.options(),
+		StartTime:              scenario.startTime,
+		Duration:               time.Since(scenario.startTime),
+		ItemsSent:              scenario.LoadGenerator.DataItemsSent(),
+		ItemsReceived:          scenario.MockBackend.DataItemsReceived(),
+		Agent:                  scenario.agentProc.GetTotalConsumption(),
+		Prometheus:             scenario.promRunner.GetTotalConsumption(),
+		PrometheusReadyLatency: scenario.promReadyLatency,
+		PrometheusMetrics:      scenario.promScraper.Last(),
+		Exporters:              newExporterStats(scenario.agentScraper.LastSamples()),
+		Receivers:              newReceiverStats(scenario.agentScraper.LastSamples()),
+		TSDB:                   scenario.tsdbFootprint,
+		DataValidation:         scenario.dataValidation,
+		OutOfOrder:             scenario.outOfOrderReport(),
+		Exemplars:              scenario.exemplars,
+		HistogramQueries:       scenario.histogramQueries,
+		Restart:                scenario.restartReport(),
+		ErrorCause:             scenario.errorCause,
+	}
+	if !scenario.loadStartTime.IsZero() {
+		stopTime := scenario.loadStopTime
+		if stopTime.IsZero() {
+			stopTime = time.Now()
+		}
+		result.LoadDuration = stopTime.Sub(scenario.loadStartTime)
+	}
+	return result
+}
+
+// outOfOrderReport returns the samples accepted and rejected by Prometheus and the collector exporter
+// when the data was sent late or out of order, nil otherwise.
+func (scenario *Scenario) outOfOrderReport() *OutOfOrderReport {
+	if scenario.outOfOrder == nil {
+		return nil
+	}
+	return newOutOfOrderReport(scenario.outOfOrder, scenario.spec.Prometheus.outOfOrderWindow(), scenario.promScraper.LastSamples(),
+		newExporterStats(scenario.agentScraper.LastSamples()), prometheusExporterName(scenario.spec.Mode))
+}
 
-	// Signal the error via channel
-	close(scenario.errorSignal)
+// restartReport returns what happened to the data sent while Prometheus restarted, nil unless it was.
+func (scenario *Scenario) restartReport() *RestartReport {
+	if scenario.promRestart == nil {
+		return nil
+	}
+	return newRestartReport(scenario.promRestart, scenario.LoadGenerator.DataItemsSent(), newExporterStats(scenario.agentScraper.LastSamples()),
+		prometheusExporterName(scenario.spec.Mode), scenario.promScraper.Samples(), scenario.composeTestResultFileName("agent.log"))
 }
 
 // StartAgent starts the agent and redirects its standard output and standard error
@@ -200,6 +559,7 @@ func (scenario *Scenario) StartAgent(args ...string) {
 			scenario.indicateError(err)
 		}
 	}()
+	scenario.agentSampler.Start()
 
 	endpoint := scenario.Sender.GetEndpoint()
 	if endpoint != nil {
@@ -207,7 +567,7 @@ func (scenario *Scenario) StartAgent(args ...string) {
 		// connect to the port to which we intend to send load. We only do this
 		// if the endpoint is not-empty, i.e. the sender does use network (some senders
 		// like text log writers don't).
-		scenario.WaitForN(func() bool {
+		started := scenario.WaitForN(func() bool {
 			conn, err := net.Dial(scenario.Sender.GetEndpoint().Network(), scenario.Sender.GetEndpoint().String())
 			if err == nil && conn != nil {
 				conn.Close()
@@ -215,25 +575,56 @@ func (scenario *Scenario) StartAgent(args ...string) {
 			}
 			return false
 		}, time.Second*10, fmt.Sprintf("connection to %s:%s", scenario.Sender.GetEndpoint().Network(), scenario.Sender.GetEndpoint().String()))
+		if !started {
+			return
+		}
 	}
+	scenario.agentScraper.Start()
 }
 
-// StartPrometheus starts the agent and redirects its standard output and standard error
-// to "agent.log" file located in the test directory.
+// StartPrometheus starts Prometheus and redirects its standard output and standard error
+// to "prometheus.log" file located in the test directory. Unless args set --storage.tsdb.path,
+// the TSDB is written to "prometheus-data" in the test directory. It returns once Prometheus is ready.
 func (scenario *Scenario) StartPrometheus(args ...string) {
 	logFileName := scenario.composeTestResultFileName("prometheus.log")
 
+	if dataDir, ok := flagValue(args, "--storage.tsdb.path"); ok {
+		scenario.promDataDir = dataDir
+		scenario.userPromDataDir = true
+	} else {
+		args = append(args, "--storage.tsdb.path="+scenario.promDataDir)
+	}
+	log.Printf("Prometheus TSDB in %s", scenario.promDataDir)
+	scenario.promArgs = args
+
+	if !scenario.startPrometheusProcess(logFileName) {
+		return
+	}
+	scenario.promSampler.Start()
+
+	// Wait for Prometheus to be ready. Prometheus accepts connections before the TSDB is opened and
+	// the WAL replayed and answers writes with 503 until then, so the port being open is not enough.
+	if latency, ok := scenario.waitForPrometheusReady(); ok {
+		scenario.promReadyLatency = latency
+		log.Printf("Prometheus ready after %s", latency)
+		scenario.promScraper.Start()
+	}
+}
+
+// startPrometheusProcess starts the Prometheus process with promArgs, writing its output to logFileName,
+// and watches its resource consumption. It returns false if the process could not be started.
+func (scenario *Scenario) startPrometheusProcess(logFileName string) bool {
 	startParams := testbed.StartParams{
 		Name:        "Prometheus",
 		LogFilePath: logFileName,
-		CmdArgs:     args,
+		CmdArgs:     scenario.promArgs,
 		// resourceSpec: &scenario.resourceSpec,
 	}
 	startParams.SetResourceSpec(&scenario.resourceSpec)
 
 	if err := scenario.promRunner.Start(startParams); err != nil {
 		scenario.indicateError(err)
-		return
+		return false
 	}
 
 	// Start watching resource consumption.
@@ -242,63 +633,254 @@ func (scenario *Scenario) StartPrometheus(args ...string) {
 			scenario.indicateError(err)
 		}
 	}()
+	return true
+}
+
+// RestartPrometheus stops Prometheus while the load keeps running, waits for downtime and starts it
+// again with the arguments and the TSDB of StartPrometheus, writing its output to "prometheus-restart.log"
+// located in the test directory. It returns once Prometheus is ready again. The resource consumption
+// and self-metrics of the stopped process are kept, Prometheus counters start again from zero.
+func (scenario *Scenario) RestartPrometheus(downtime time.Duration) {
+	if scenario.Failed() {
+		return
+	}
+	restart := &prometheusRestart{storedSamples: -1}
+	scenario.promRestart = restart
+
+	// Keep the final counters of the stopped process.
+	scenario.promScraper.ScrapeNow()
+	restart.itemsBefore = scenario.LoadGenerator.DataItemsSent()
+	restart.stoppedAt = time.Now()
+	log.Printf("Restarting Prometheus, down for %s", downtime)
+	if _, err := scenario.promRunner.Stop(); err != nil {
+		scenario.indicateError(fmt.Errorf("cannot stop Prometheus for restart: %w", err))
+		return
+	}
+
+	scenario.Sleep(downtime)
+	if scenario.Failed() {
+		return
+	}
+	if !scenario.startPrometheusProcess(scenario.composeTestResultFileName("prometheus-restart.log")) {
+		return
+	}
+	if latency, ok := scenario.waitForPrometheusReady(); ok {
+		restart.recovery = latency
+		restart.readyAt = time.Now()
+		restart.itemsAfter = scenario.LoadGenerator.DataItemsSent()
+		log.Printf("Prometheus ready again after %s, down for %s", latency, restart.readyAt.Sub(restart.stoppedAt))
+	}
+}
 
-	// endpoint := scenario.Sender.GetEndpoint()
-	endpoint, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("127.0.0.1:%d", PortPrometheus))
+// MeasureRestart counts the samples of the load generator Prometheus stored, to be compared with the
+// data sent once the processes stopped. Call it after StopLoad and RestartPrometheus, while Prometheus
+// is still running.
+func (scenario *Scenario) MeasureRestart() {
+	if scenario.promRestart == nil {
+		return
+	}
+	client := NewPrometheusClient(AddressLocalhost, scenario.promPort)
+	stored, err := countStoredSamples(client, scenario.loadStartTime, 30*time.Second)
 	if err != nil {
-		scenario.indicateError(err)
+		log.Printf("Cannot count the samples stored by Prometheus: %s", err.Error())
 		return
 	}
+	scenario.promRestart.storedSamples = stored
+}
 
-	if endpoint != nil {
-		// Wait for Prometheus to start. We consider the Prometheus started when we can
-		// connect to the port to which we intend to send load. We only do this
-		// if the endpoint is not-empty, i.e. the sender does use network (some senders
-		// like text log writers don't).
-		scenario.WaitForN(func() bool {
-			conn, err := net.Dial(endpoint.Network(), endpoint.String())
-			if err == nil && conn != nil {
-				conn.Close()
-				return true
+// waitForPrometheusReady polls /-/healthy and then /-/ready until both answer 200 OK, for up to the
+// ready timeout. It returns the time from starting to wait until Prometheus was ready, or false if
+// Prometheus did not become ready or an error was signaled while waiting.
+func (scenario *Scenario) waitForPrometheusReady() (time.Duration, bool) {
+	client := NewPrometheusClient(AddressLocalhost, scenario.promPort)
+	startTime := time.Now()
+	waitInterval := time.Millisecond * 5
+
+	var lastErr error
+	for _, endpoint := range []string{"/-/healthy", "/-/ready"} {
+		for {
+			if lastErr = client.CheckEndpoint(endpoint); lastErr == nil {
+				break
 			}
-			return false
-		}, time.Second*10, fmt.Sprintf("connection to %s:%s", endpoint.Network(), endpoint.String()))
+
+			if time.Since(startTime) > scenario.promReadyTimeout {
+				scenario.indicateError(fmt.Errorf("Prometheus did not become ready within %s: %w", scenario.promReadyTimeout, lastErr))
+				return 0, false
+			}
+
+			select {
+			case <-time.After(waitInterval):
+			case <-scenario.errorSignal:
+				return 0, false
+			}
+
+			// Increase waiting interval exponentially up to 500 ms.
+			if waitInterval < time.Millisecond*500 {
+				waitInterval *= 2
+			}
+		}
 	}
+
+	return time.Since(startTime), true
 }
 
-// StopAgent stops agent process.
+// StopAgent stops agent process, after scraping its internal telemetry a last time.
 func (scenario *Scenario) StopAgent() {
+	scenario.agentScraper.Stop()
 	if _, err := scenario.agentProc.Stop(); err != nil {
 		scenario.indicateError(err)
 	}
 }
 
-// StopPrometheus stops prometheus process.
+// StopPrometheus stops prometheus process, after scraping its metrics a last time, and measures its
+// TSDB so that Result reports it.
 func (scenario *Scenario) StopPrometheus() {
+	scenario.promScraper.Stop()
 	if _, err := scenario.promRunner.Stop(); err != nil {
 		scenario.indicateError(err)
 	}
+	scenario.measureTSDB()
+}
+
+// measureTSDB measures the footprint of the TSDB of the stopped Prometheus, see TSDBFootprint, and
+// analyzes it with promtool if enabled by WithTSDBAnalysis. The TSDB is measured once.
+func (scenario *Scenario) measureTSDB() {
+	if scenario.tsdbFootprint != nil {
+		return
+	}
+	if _, err := os.Stat(scenario.promDataDir); err != nil {
+		// Prometheus did not start.
+		return
+	}
+	footprint, err := newTSDBFootprint(scenario.promDataDir, scenario.promScraper.LastSamples())
+	if err != nil {
+		log.Printf("Cannot measure the TSDB in %s: %s", scenario.promDataDir, err.Error())
+		return
+	}
+	scenario.tsdbFootprint = footprint
+	log.Printf("Prometheus TSDB %s", footprint)
 
+	if !scenario.analyzeTSDB {
+		return
+	}
+	pr, ok := scenario.promRunner.(*PrometheusRunner)
+	if !ok {
+		return
+	}
+	if err = analyzeTSDB(scenario.promDataDir, pr.agentExePath, scenario.composeTestResultFileName("tsdb_analyze.txt")); err != nil {
+		log.Printf("Cannot analyze the TSDB: %s", err.Error())
+	}
 }
 
-// RemotePrometheusData removes Prometheus directory.
-func (scenario *Scenario) RemovePrometheusData(path string) {
-	if path == "" {
-		path = "./data"
+// RemovePrometheusData removes the TSDB directory of Prometheus, unless it is kept by WithKeepPrometheusData
+// or was given by --storage.tsdb.path. Call it after stopping Prometheus.
+func (scenario *Scenario) RemovePrometheusData() {
+	if scenario.userPromDataDir {
+		log.Printf("Prometheus data in %s left in place, it was not created by the scenario", scenario.promDataDir)
+		return
+	}
+	if scenario.keepPromData {
+		log.Printf("Prometheus data kept in %s", scenario.promDataDir)
+		return
+	}
+	if err := os.RemoveAll(scenario.promDataDir); err != nil {
+		log.Printf("Cannot remove %s: %s", scenario.promDataDir, err.Error())
 	}
-	pr, _ := scenario.promRunner.(*PrometheusRunner)
-	pr.CleanDataDir(path)
 }
 
 // StartLoad starts the load generator and redirects its standard output and standard error
 // to "load-generator.log" file located in the test directory.
 func (scenario *Scenario) StartLoad(options testbed.LoadOptions) {
+	scenario.loadStartTime = time.Now()
 	scenario.LoadGenerator.Start(options)
 }
 
 // StopLoad stops load generator.
 func (scenario *Scenario) StopLoad() {
 	scenario.LoadGenerator.Stop()
+	if !scenario.loadStartTime.IsZero() && scenario.loadStopTime.IsZero() {
+		scenario.loadStopTime = time.Now()
+	}
+}
+
+// ValidatePrometheusData compares the data generated since the load started with the data stored by
+// Prometheus and writes the report to "data_validation.json" located in the test directory. Missing
+// or extra data fails the scenario. Call it after StopLoad, while Prometheus is still running.
+func (scenario *Scenario) ValidatePrometheusData() *DataValidationReport {
+	if scenario.recorder == nil {
+		log.Printf("Data validation skipped, the generated data was not recorded")
+		return nil
+	}
+
+	validator := NewPrometheusDataValidator(NewPrometheusClient(AddressLocalhost, scenario.promPort), scenario.recorder)
+	report, err := validator.Validate(scenario.loadStartTime)
+	if err != nil {
+		scenario.indicateError(fmt.Errorf("cannot validate Prometheus data: %w", err))
+		return nil
+	}
+	scenario.dataValidation = report
+
+	logValidationReport(report)
+	if err = writeJSONFile(scenario.composeTestResultFileName("data_validation.json"), report); err != nil {
+		log.Printf("Cannot write data validation report: %s", err.Error())
+	}
+	if !report.OK() {
+		scenario.indicateError(fmt.Errorf("Prometheus data does not match generated data: %s", report))
+	}
+	return report
+}
+
+// ValidateExemplars compares the exemplars attached by the histogram provider with those Prometheus
+// stored, logs the result and writes it to "exemplars.json" located in the test directory. Exemplars
+// lost on the way are reported, not an error.
+func (scenario *Scenario) ValidateExemplars() *ExemplarReport {
+	if scenario.histograms == nil {
+		return nil
+	}
+	client := NewPrometheusClient(AddressLocalhost, scenario.promPort)
+	report, err := validateExemplars(client, scenario.histograms.Exemplars(), scenario.loadStartTime)
+	if err != nil {
+		scenario.indicateError(fmt.Errorf("cannot validate exemplars: %w", err))
+		return nil
+	}
+	scenario.exemplars = report
+
+	logExemplarReport(report)
+	if err = writeJSONFile(scenario.composeTestResultFileName("exemplars.json"), report); err != nil {
+		log.Printf("Cannot write exemplar report: %s", err.Error())
+	}
+	return report
+}
+
+// QueryHistograms evaluates the histogram queries over the classic and exponential histograms of the
+// histogram provider at the time the load stopped, logs the results and writes them to
+// "histogram_queries.json" located in the test directory. Failed queries are reported, not an error.
+func (scenario *Scenario) QueryHistograms() []HistogramQueryResult {
+	var types []string
+	for _, typ := range scenario.spec.Load.Histograms.Types {
+		if _, ok := histogramQueries[typ]; ok {
+			types = append(types, typ)
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+
+	client := NewPrometheusClient(AddressLocalhost, scenario.promPort)
+	results := queryHistograms(client, types, scenario.loadStopTime)
+	scenario.histogramQueries = results
+
+	for _, r := range results {
+		if r.Error != "" {
+			log.Printf("Histogram query %s %s failed: %s", r.Type, r.Name, r.Error)
+			continue
+		}
+		log.Printf("Histogram query %s %s: %s in %.1fms", r.Type, r.Name, formatQueryValue(r.Value, 3), 1000*r.LatencySeconds)
+	}
+	if err := writeJSONFile(scenario.composeTestResultFileName("histogram_queries.json"), results); err != nil {
+		log.Printf("Cannot write histogram query results: %s", err.Error())
+	}
+	return results
 }
 
 // StartBackend starts the specified backend type.
@@ -315,7 +897,7 @@ func (scenario *Scenario) StopBackend() {
 	scenario.MockBackend.Stop()
 }
 
-// WaitForN the specific condition for up to a specified duration. Records a test error
+// WaitForN the specific condition for up to a specified duration. Indicates an error
 // if time is out and condition does not become true. If error is signaled
 // while waiting the function will return false, but will not record additional
 // test error (we assume that signaled error is already recorded in indicateError()).
@@ -343,7 +925,7 @@ func (scenario *Scenario) WaitForN(cond func() bool, duration time.Duration, err
 
 		if time.Since(startTime) > duration {
 			// Waited too long
-			log.Fatalf("Time out waiting for %s", errMsg)
+			scenario.indicateError(fmt.Errorf("Time out waiting for %s", errMsg))
 			return false
 		}
 	}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"gopkg.in/yaml.v3"
+)
+
+// ScenarioSpec describes a whole benchmark run. It is read from a YAML or JSON scenario file, JSON being
+// a subset of YAML both formats are parsed by the YAML decoder.
+type ScenarioSpec struct {
+	// Name of the scenario, results are written to results/<name>.
+	Name string `yaml:"name"`
+	// Mode is the ingestion path from the collector to Prometheus.
+	Mode IngestionMode `yaml:"mode"`
+	// Duration of the load. Zero falls back to the TEST_DURATION env variable.
+	Duration time.Duration `yaml:"duration"`
+	// ValidateData records the generated data and checks after the load that Prometheus stored all of
+	// it. Ignored in mock mode.
+	ValidateData bool `yaml:"validate_data"`
+
+	Sender     SenderSpec     `yaml:"sender"`
+	Receiver   ReceiverSpec   `yaml:"receiver"`
+	Collector  CollectorSpec  `yaml:"collector"`
+	Prometheus PrometheusSpec `yaml:"prometheus"`
+	Load       LoadSpec       `yaml:"load"`
+	Resources  ResourcesSpec  `yaml:"resources"`
+
+	// Matrix is only used by the matrix command, and its modes by the conformance command.
+	Matrix MatrixSpec `yaml:"matrix"`
+}
+
+// SenderSpec describes the load generator's sender, i.e. the collector receiver.
+type SenderSpec struct {
+	// Type is the protocol, "otlphttp" or "otlp" (gRPC).
+	Type string `yaml:"type"`
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+// ReceiverSpec describes the mock backend receiver, i.e. the collector exporter.
+type ReceiverSpec struct {
+	// Type is the protocol, "otlphttp" or "otlp" (gRPC).
+	Type string `yaml:"type"`
+	Port int    `yaml:"port"`
+}
+
+// CollectorSpec describes the collector process and the extra components of its config.
+type CollectorSpec struct {
+	ExePath string `yaml:"exe_path"`
+	// TelemetryPort is the port of the collector's internal metrics endpoint, scraped during the run.
+	TelemetryPort int `yaml:"telemetry_port"`
+	// PprofPort is the port of the pprof extension, which writes the CPU profile of the run.
+	PprofPort int `yaml:"pprof_port"`
+	// Processors and Extensions map component names to their config, e.g. "batch: {send_batch_size: 1000}".
+	// Processors are added to the pipeline in the order they are listed.
+	Processors ComponentList `yaml:"processors"`
+	Extensions ComponentList `yaml:"extensions"`
+	// DeltaToCumulative adds the deltatocumulative processor after Processors, converting delta sums
+	// and histograms before they reach the Prometheus exporters.
+	DeltaToCumulative bool `yaml:"delta_to_cumulative"`
+	// ResourceToTelemetry enables resource_to_telemetry_conversion of the prometheusremotewrite exporter,
+	// adding all resource attributes as labels to every series.
+	ResourceToTelemetry bool `yaml:"resource_to_telemetry_conversion"`
+	// Exporters holds settings of the exporter to Prometheus by ingestion mode, replacing the defaults
+	// of the same name, e.g. "otlp-native: {retry_on_failure: {max_elapsed_time: 1m}}".
+	Exporters map[IngestionMode]map[string]interface{} `yaml:"exporters"`
+}
+
+// PrometheusSpec describes the Prometheus process.
+type PrometheusSpec struct {
+	ExePath string `yaml:"exe_path"`
+	Port    int    `yaml:"port"`
+	// Flags are passed to Prometheus in addition to --web.listen-address.
+	Flags []string `yaml:"flags"`
+	// ReadyTimeout is the maximum time to wait for /-/ready after start.
+	ReadyTimeout time.Duration `yaml:"ready_timeout"`
+	// Config holds the settings of the Prometheus config file, see newPrometheusConfig.
+	Config PrometheusConfig `yaml:"config"`
+	// KeepData keeps the TSDB in the results directory after the run instead of removing it.
+	KeepData bool `yaml:"keep_data"`
+	// AnalyzeTSDB writes the output of "promtool tsdb analyze" on the TSDB to tsdb_analyze.txt.
+	AnalyzeTSDB bool `yaml:"analyze_tsdb"`
+	// ExemplarStorage starts Prometheus with --enable-feature=exemplar-storage, without it exemplars
+	// are dropped.
+	ExemplarStorage bool `yaml:"exemplar_storage"`
+	// NativeHistograms starts Prometheus with --enable-feature=native-histograms. Without it both the OTLP
+	// receiver and the remote write receiver drop exponential histograms, which the prometheusremotewrite
+	// exporter always sends as native histograms: it has no option to turn them into classic ones.
+	NativeHistograms bool `yaml:"native_histograms"`
+	// Restart stops and starts Prometheus again during the load.
+	Restart RestartSpec `yaml:"restart"`
+}
+
+// LoadSpec mirrors testbed.LoadOptions and selects the data provider generating the load.
+type LoadSpec struct {
+	DataItemsPerSecond int               `yaml:"data_items_per_second"`
+	ItemsPerBatch      int               `yaml:"items_per_batch"`
+	Parallel           int               `yaml:"parallel"`
+	Attributes         map[string]string `yaml:"attributes"`
+	// Provider is the data provider: perf for the gauges of NewPerfDataProvider, histograms
+	// for NewHistogramDataProvider or cardinality for NewCardinalityDataProvider.
+	Provider string `yaml:"provider"`
+	// Histograms and Cardinality configure the histograms and cardinality providers.
+	Histograms  HistogramSpec   `yaml:"histograms"`
+	Cardinality CardinalitySpec `yaml:"cardinality"`
+	// OutOfOrder shifts the timestamps of the data of any provider to send it late and out of order.
+	OutOfOrder OutOfOrderSpec `yaml:"out_of_order"`
+}
+
+// ResourcesSpec mirrors testbed.ResourceSpec.
+type ResourcesSpec struct {
+	ExpectedMaxCPU         uint32        `yaml:"expected_max_cpu"`
+	ExpectedMaxRAM         uint32        `yaml:"expected_max_ram"`
+	ResourceCheckPeriod    time.Duration `yaml:"resource_check_period"`
+	MaxConsecutiveFailures uint32        `yaml:"max_consecutive_failures"`
+	// SamplePeriod is the interval of the resource usage time series written to resource_usage.csv.
+	SamplePeriod time.Duration `yaml:"sample_period"`
+}
+
+// DefaultScenarioSpec returns the scenario used when no scenario file is given. Its ports are zero, free
+// ports are allocated when the scenario is built.
+func DefaultScenarioSpec() ScenarioSpec {
+	return ScenarioSpec{
+		Name: AppName,
+		Mode: ModeOTLPNative,
+		Sender: SenderSpec{
+			Type: "otlphttp",
+			Host: AddressLocalhost,
+		},
+		Receiver: ReceiverSpec{
+			Type: "otlphttp",
+		},
+		Collector: CollectorSpec{
+			ExePath: ExePathOtelCollector,
+		},
+		Prometheus: PrometheusSpec{
+			ExePath:      ExePathPrometheus,
+			ReadyTimeout: time.Minute,
+			Flags: []string{
+				"--enable-feature=otlp-write-receiver",
+				"--web.enable-remote-write-receiver",
+			},
+		},
+		Load: LoadSpec{
+			DataItemsPerSecond: SamplesPerSecond,
+			ItemsPerBatch:      100,
+			Parallel:           1,
+			Provider:           "perf",
+			Histograms:         DefaultHistogramSpec(),
+			Cardinality:        DefaultCardinalitySpec(),
+			OutOfOrder: OutOfOrderSpec{
+				MaxShift: time.Minute,
+				Seed:     1,
+			},
+		},
+		Resources: ResourcesSpec{
+			ExpectedMaxCPU:      1200,
+			ExpectedMaxRAM:      5500,
+			ResourceCheckPeriod: 3 * time.Second,
+			SamplePeriod:        time.Second,
+		},
+	}
+}
+
+// LoadScenarioSpec reads a YAML or JSON scenario file. Settings missing from the file keep the values
+// of DefaultScenarioSpec.
+func LoadScenarioSpec(fileName string) (ScenarioSpec, error) {
+	spec := DefaultScenarioSpec()
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return spec, err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		return spec, fmt.Errorf("cannot parse scenario file %s: %w", fileName, err)
+	}
+
+	if err = spec.Validate(); err != nil {
+		return spec, fmt.Errorf("invalid scenario file %s: %w", fileName, err)
+	}
+	return spec, nil
+}
+
+// Validate checks the spec for settings the scenario cannot run with.
+func (spec *ScenarioSpec) Validate() error {
+	if spec.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	mode := spec.Mode
+	if err := mode.Set(string(spec.Mode)); err != nil {
+		return err
+	}
+	if spec.Duration < 0 {
+		return fmt.Errorf("negative duration %s", spec.Duration)
+	}
+	for _, port := range []int{spec.Sender.Port, spec.Receiver.Port, spec.Collector.TelemetryPort, spec.Collector.PprofPort, spec.Prometheus.Port} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	if spec.Prometheus.ReadyTimeout <= 0 {
+		return errors.New("prometheus ready_timeout must be greater than zero")
+	}
+	if err := spec.Prometheus.Restart.Validate(spec.Duration); err != nil {
+		return err
+	}
+	if spec.Prometheus.Restart.Enabled() && spec.Load.Provider != "perf" && spec.Load.Provider != "cardinality" {
+		// The lost samples compare data points with samples, which only match for gauges.
+		return fmt.Errorf("prometheus restart needs the perf or cardinality provider, not %q", spec.Load.Provider)
+	}
+	for mode := range spec.Collector.Exporters {
+		if err := mode.Set(string(mode)); err != nil || mode == ModeMock {
+			return fmt.Errorf("collector exporters: no exporter to Prometheus in mode %q", mode)
+		}
+	}
+	if _, err := spec.Sender.build(); err != nil {
+		return err
+	}
+	if _, err := spec.Receiver.build(); err != nil {
+		return err
+	}
+	if spec.Resources.SamplePeriod <= 0 {
+		return errors.New("resources sample_period must be greater than zero")
+	}
+	if spec.Load.DataItemsPerSecond <= 0 || spec.Load.ItemsPerBatch <= 0 || spec.Load.Parallel <= 0 {
+		return errors.New("load data_items_per_second, items_per_batch and parallel must be greater than zero")
+	}
+	if _, err := spec.Load.dataProvider(); err != nil {
+		return err
+	}
+	return spec.Matrix.Validate()
+}
+
+func (s SenderSpec) build() (testbed.DataSender, error) {
+	switch s.Type {
+	case "otlphttp":
+		return testbed.NewOTLPHTTPMetricDataSender(s.Host, s.Port), nil
+	case "otlp":
+		return testbed.NewOTLPMetricDataSender(s.Host, s.Port), nil
+	default:
+		return nil, fmt.Errorf("unknown sender type %q, expecting otlphttp or otlp", s.Type)
+	}
+}
+
+func (r ReceiverSpec) build() (testbed.DataReceiver, error) {
+	switch r.Type {
+	case "otlphttp":
+		return testbed.NewOTLPHTTPDataReceiver(r.Port), nil
+	case "otlp":
+		return testbed.NewOTLPDataReceiver(r.Port), nil
+	default:
+		return nil, fmt.Errorf("unknown receiver type %q, expecting otlphttp or otlp", r.Type)
+	}
+}
+
+// outOfOrderWindow returns the storage.tsdb.out_of_order_time_window of the config, zero if not set.
+func (p PrometheusSpec) outOfOrderWindow() time.Duration {
+	if p.Config.Storage == nil || p.Config.Storage.TSDB == nil {
+		return 0
+	}
+	return time.Duration(p.Config.Storage.TSDB.OutOfOrderTimeWindow)
+}
+
+// args returns the Prometheus command line arguments.
+func (p PrometheusSpec) args() []string {
+	args := append([]string{fmt.Sprintf("--web.listen-address=:%d", p.Port)}, p.Flags...)
+	if p.ExemplarStorage {
+		args = append(args, "--enable-feature=exemplar-storage")
+	}
+	if p.NativeHistograms {
+		args = append(args, "--enable-feature=native-histograms")
+	}
+	return args
+}
+
+func (l LoadSpec) options() testbed.LoadOptions {
+	return testbed.LoadOptions{
+		DataItemsPerSecond: l.DataItemsPerSecond,
+		ItemsPerBatch:      l.ItemsPerBatch,
+		Parallel:           l.Parallel,
+		Attributes:         l.Attributes,
+	}
+}
+
+// dataProvider creates the provider of the spec, wrapped by an outOfOrderDataProvider if enabled.
+func (l LoadSpec) dataProvider() (testbed.DataProvider, error) {
+	provider, err := l.baseDataProvider()
+	if err != nil || !l.OutOfOrder.Enabled() {
+		return provider, err
+	}
+	if err = l.OutOfOrder.Validate(); err != nil {
+		return nil, err
+	}
+	if l.Provider == "perf" {
+		// Every batch has new series, none can be out of order.
+		return nil, errors.New("load out_of_order needs the histograms or cardinality provider")
+	}
+	return newOutOfOrderDataProvider(provider, l.OutOfOrder), nil
+}
+
+func (l LoadSpec) baseDataProvider() (testbed.DataProvider, error) {
+	switch l.Provider {
+	case "perf":
+		return NewPerfDataProvider(l.options()), nil
+	case "histograms":
+		if err := l.Histograms.Validate(); err != nil {
+			return nil, err
+		}
+		return NewHistogramDataProvider(l.options(), l.Histograms), nil
+	case "cardinality":
+		if err := l.Cardinality.Validate(); err != nil {
+			return nil, err
+		}
+		if l.ItemsPerBatch > l.Cardinality.Series {
+			// A series sampled twice in a batch has two samples of the same timestamp.
+			return nil, fmt.Errorf("load items_per_batch %d exceeds the cardinality series %d", l.ItemsPerBatch, l.Cardinality.Series)
+		}
+		return NewCardinalityDataProvider(l.options(), l.Cardinality), nil
+	default:
+		return nil, fmt.Errorf("unknown load provider %q, expecting perf, histograms or cardinality", l.Provider)
+	}
+}
+
+func (r ResourcesSpec) resourceSpec() testbed.ResourceSpec {
+	return testbed.ResourceSpec{
+		ExpectedMaxCPU:         r.ExpectedMaxCPU,
+		ExpectedMaxRAM:         r.ExpectedMaxRAM,
+		ResourceCheckPeriod:    r.ResourceCheckPeriod,
+		MaxConsecutiveFailures: r.MaxConsecutiveFailures,
+	}
+}
+
+// allocatePorts replaces the zero ports of the spec by free ports, so that several scenarios can run
+// on the same machine at the same time.
+func (spec *ScenarioSpec) allocatePorts() error {
+	ports := []*int{&spec.Sender.Port, &spec.Receiver.Port, &spec.Collector.TelemetryPort, &spec.Collector.PprofPort, &spec.Prometheus.Port}
+
+	// Keep all listeners open until every port is allocated, so that no port is returned twice.
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	for _, port := range ports {
+		if *port != 0 {
+			continue
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(AddressLocalhost, "0"))
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+		*port = l.Addr().(*net.TCPAddr).Port
+	}
+	return nil
+}
+
+// NewScenarioFromSpec builds the sender, receiver, collector and Prometheus runners and their configs
+// from the spec and creates the Scenario running them. The prepared configs are removed by Scenario.Stop,
+// which also adds the results of the scenario to resultsSummary. An invalid spec or config is returned
+// as error, so that the other scenarios of a run can go on.
+func NewScenarioFromSpec(spec ScenarioSpec, resultsSummary testbed.TestResultsSummary) (*Scenario, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", spec.Name, err)
+	}
+
+	if err := spec.allocatePorts(); err != nil {
+		return nil, fmt.Errorf("cannot allocate ports: %w", err)
+	}
+	log.Printf("Ports: collector receiver %d, mock backend %d, collector telemetry %d, collector pprof %d, Prometheus %d",
+		spec.Sender.Port, spec.Receiver.Port, spec.Collector.TelemetryPort, spec.Collector.PprofPort, spec.Prometheus.Port)
+
+	sender, _ := spec.Sender.build()
+	receiver, _ := spec.Receiver.build()
+
+	resultDir, err := filepath.Abs(path.Join("results", spec.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	agentProc := testbed.NewChildProcessCollector(testbed.WithAgentExePath(spec.Collector.ExePath))
+
+	config, err := newCollectorConfig(spec, sender, receiver, resultDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create collector config: %w", err)
+	}
+	configStr, err := config.YAML()
+	if err != nil {
+		return nil, fmt.Errorf("cannot render collector config: %w", err)
+	}
+	log.Printf("Otel Config: %s", configStr)
+	if err = checkCollectorConfig(configStr, spec.Collector.ExePath); err != nil {
+		return nil, fmt.Errorf("invalid collector config: %w", err)
+	}
+	configCleanupOtel, err := agentProc.PrepareConfig(configStr)
+	if err != nil {
+		return nil, err
+	}
+
+	promRunner := NewPrometheusRunner(WithAgentExePath(spec.Prometheus.ExePath))
+	configCleanupProm, err := promRunner.PreparePrometheusConfig(newPrometheusConfig(spec.Prometheus))
+	if err != nil {
+		configCleanupOtel()
+		return nil, err
+	}
+
+	dataProvider, _ := spec.Load.dataProvider()
+	outOfOrder, _ := dataProvider.(*outOfOrderDataProvider)
+	histograms, _ := dataProvider.(*histogramDataProvider)
+	if outOfOrder != nil {
+		histograms, _ = outOfOrder.DataProvider.(*histogramDataProvider)
+	}
+	var recorder *recordingDataProvider
+	if spec.ValidateData && spec.Mode != ModeMock {
+		recorder = newRecordingDataProvider(dataProvider)
+		dataProvider = recorder
+	}
+	log.Println("DataProvider created", dataProvider)
+
+	opts := []ScenarioOption{
+		WithPrometheusPort(spec.Prometheus.Port),
+		WithCollectorTelemetryPort(spec.Collector.TelemetryPort),
+		WithPrometheusReadyTimeout(spec.Prometheus.ReadyTimeout),
+		WithKeepPrometheusData(spec.Prometheus.KeepData),
+		WithTSDBAnalysis(spec.Prometheus.AnalyzeTSDB),
+		WithResourceSamplePeriod(spec.Resources.SamplePeriod),
+	}
+	if spec.Duration > 0 {
+		opts = append(opts, WithDuration(spec.Duration))
+	}
+
+	scenario, err := NewScenario(
+		spec.Name,
+		dataProvider,
+		sender,
+		receiver,
+		agentProc,
+		promRunner,
+		&PerfScenarioValidator{},
+		resultsSummary,
+		spec.Resources.resourceSpec(),
+		opts...,
+	)
+	if err != nil {
+		configCleanupProm()
+		configCleanupOtel()
+		return nil, err
+	}
+	scenario.spec = spec
+	scenario.recorder = recorder
+	scenario.outOfOrder = outOfOrder
+	scenario.histograms = histograms
+	scenario.configCleanups = append(scenario.configCleanups, configCleanupProm, configCleanupOtel)
+
+	return scenario, nil
+}
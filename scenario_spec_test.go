@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadScenarioSpec(t *testing.T) {
+	spec, err := LoadScenarioSpec(filepath.Join("scenarios", "otlp-native.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Mode != ModeOTLPNative || spec.Duration != time.Minute {
+		t.Errorf("unexpected mode %s or duration %s", spec.Mode, spec.Duration)
+	}
+	if len(spec.Collector.Processors) == 0 || spec.Collector.Processors[0].Name != "batch" {
+		t.Errorf("batch processor missing: %v", spec.Collector.Processors)
+	}
+
+	// JSON files are parsed the same way and keep the defaults of missing settings.
+	spec, err = LoadScenarioSpec(filepath.Join("scenarios", "mock.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Mode != ModeMock || spec.Duration != 30*time.Second {
+		t.Errorf("unexpected mode %s or duration %s", spec.Mode, spec.Duration)
+	}
+	if spec.Prometheus.Port != 0 || spec.Sender.Type != "otlphttp" {
+		t.Errorf("defaults not kept: %+v", spec)
+	}
+
+	spec, err = LoadScenarioSpec(filepath.Join("scenarios", "histograms.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Load.Provider != "histograms" || len(spec.Load.Histograms.Types) != 3 {
+		t.Errorf("unexpected load %+v", spec.Load)
+	}
+
+	spec, err = LoadScenarioSpec(filepath.Join("scenarios", "cardinality.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta, err := LoadScenarioSpec(filepath.Join("scenarios", "delta.yaml")); err != nil || delta.Load.Histograms.Temporality != temporalityDelta {
+		t.Errorf("cannot load delta scenario: %v", err)
+	}
+	if spec.Load.Provider != "cardinality" || len(spec.Load.Cardinality.Labels) != 2 || spec.Load.Cardinality.ChurnPerMinute != 10000 {
+		t.Errorf("unexpected load %+v", spec.Load)
+	}
+
+	spec, err = LoadScenarioSpec(filepath.Join("scenarios", "out_of_order.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Prometheus.outOfOrderWindow() != 5*time.Minute || !spec.Load.OutOfOrder.Enabled() || spec.Load.OutOfOrder.Seed != 1 {
+		t.Errorf("unexpected out-of-order settings %+v %+v", spec.Prometheus.Config.Storage, spec.Load.OutOfOrder)
+	}
+	if exemplars, err := LoadScenarioSpec(filepath.Join("scenarios", "exemplars.yaml")); err != nil ||
+		!exemplars.Load.Histograms.Exemplars || exemplars.Prometheus.args()[len(exemplars.Prometheus.args())-1] != "--enable-feature=exemplar-storage" {
+		t.Errorf("cannot load exemplars scenario: %v", err)
+	}
+	if native, err := LoadScenarioSpec(filepath.Join("scenarios", "native_histograms.yaml")); err != nil ||
+		!native.Prometheus.NativeHistograms || len(native.Load.Histograms.Types) != 2 {
+		t.Errorf("cannot load native histograms scenario: %v", err)
+	}
+	if restart, err := LoadScenarioSpec(filepath.Join("scenarios", "restart.yaml")); err != nil ||
+		restart.Prometheus.Restart.After != 20*time.Second || len(restart.Collector.Exporters[ModeOTLPNative]) != 2 {
+		t.Errorf("cannot load restart scenario: %v", err)
+	}
+	if resources, err := LoadScenarioSpec(filepath.Join("scenarios", "resource_attributes.yaml")); err != nil ||
+		len(resourceVariants(resources.Prometheus.Config.OTLP.PromoteResourceAttributes)[1].Promote) != 3 {
+		t.Errorf("cannot load resource attributes scenario: %v", err)
+	}
+	if provider, _ := spec.Load.dataProvider(); provider == nil {
+		t.Errorf("no data provider")
+	} else if _, ok := provider.(*outOfOrderDataProvider); !ok {
+		t.Errorf("got provider %T, want it wrapped for out-of-order data", provider)
+	}
+}
+
+func TestLoadScenarioSpecInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown mode":      "mode: carrier-pigeon\n",
+		"unknown field":     "load:\n  rate: 10\n",
+		"zero batch":        "load:\n  items_per_batch: 0\n",
+		"late restart":      "duration: 10s\nprometheus:\n  restart:\n    after: 10s\n",
+		"long restart":      "duration: 30s\nprometheus:\n  restart:\n    after: 20s\n    downtime: 10s\n",
+		"histogram restart": "duration: 30s\nprometheus:\n  restart:\n    after: 10s\nload:\n  provider: histograms\n",
+		"mock exporter":     "collector:\n  exporters:\n    mock: {}\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "scenario.yaml")
+			if err := os.WriteFile(fileName, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadScenarioSpec(fileName); err == nil {
+				t.Errorf("expected error for %q", content)
+			}
+		})
+	}
+}
+
+func TestAllocatePorts(t *testing.T) {
+	spec := DefaultScenarioSpec()
+	spec.Receiver.Port = 34688
+	if err := spec.allocatePorts(); err != nil {
+		t.Fatal(err)
+	}
+
+	ports := []int{spec.Sender.Port, spec.Receiver.Port, spec.Collector.TelemetryPort, spec.Collector.PprofPort, spec.Prometheus.Port}
+	if spec.Receiver.Port != 34688 {
+		t.Errorf("configured port replaced by %d", spec.Receiver.Port)
+	}
+	seen := map[int]bool{}
+	for _, port := range ports {
+		if port <= 0 || seen[port] {
+			t.Errorf("got ports %v, want distinct free ports", ports)
+		}
+		seen[port] = true
+	}
+}
+
+func TestComponentListOrder(t *testing.T) {
+	var collector CollectorSpec
+	doc := "processors:\n  memory_limiter:\n    limit_mib: 512\n  batch:\n  attributes:\n    actions: []\n"
+	if err := yaml.Unmarshal([]byte(doc), &collector); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, processor := range collector.Processors {
+		names = append(names, processor.Name)
+	}
+	if strings.Join(names, ",") != "memory_limiter,batch,attributes" {
+		t.Errorf("got processors %v, want them in file order", names)
+	}
+	if collector.Processors[1].Config != nil {
+		t.Errorf("got batch config %v, want nil", collector.Processors[1].Config)
+	}
+
+	if err := yaml.Unmarshal([]byte("processors: [batch]\n"), &collector); err == nil {
+		t.Error("expected error for a list of processors")
+	}
+}
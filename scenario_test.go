@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+)
+
+// newTestScenario returns a scenario of the default spec without processes, writing its results to a
+// temporary directory.
+func newTestScenario(t *testing.T) *Scenario {
+	spec := DefaultScenarioSpec()
+	sender, _ := spec.Sender.build()
+	receiver, _ := spec.Receiver.build()
+	dataProvider, _ := spec.Load.dataProvider()
+	loadGenerator, err := testbed.NewLoadGenerator(dataProvider, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	return &Scenario{
+		name:             spec.Name,
+		spec:             spec,
+		resultDir:        dir,
+		startTime:        time.Now(),
+		errorSignal:      make(chan struct{}),
+		doneSignal:       make(chan struct{}),
+		Sender:           sender,
+		receiver:         receiver,
+		LoadGenerator:    loadGenerator,
+		MockBackend:      testbed.NewMockBackend(filepath.Join(dir, "backend.log"), receiver),
+		agentProc:        testbed.NewChildProcessCollector(),
+		promRunner:       NewPrometheusRunner(),
+		promDataDir:      filepath.Join(dir, "prometheus-data"),
+		promReadyTimeout: time.Minute,
+		promScraper:      NewMetricsScraper("prometheus", fmt.Sprintf("http://%s:%d/metrics", AddressLocalhost, PortPrometheus), time.Second, prometheusSelfMetrics),
+		agentScraper:     NewMetricsScraper("collector", fmt.Sprintf("http://%s:%d/metrics", AddressLocalhost, PortCollectorTelemetry), time.Second, collectorSelfMetrics),
+	}
+}
+
+func TestNewScenarioError(t *testing.T) {
+	t.Setenv("TEST_DURATION", "soon")
+	spec := DefaultScenarioSpec()
+	dataProvider, _ := spec.Load.dataProvider()
+	_, err := NewScenario(spec.Name, dataProvider, nil, nil, testbed.NewChildProcessCollector(), NewPrometheusRunner(),
+		&PerfScenarioValidator{}, &ScenarioResults{}, spec.Resources.resourceSpec())
+	if err == nil || !strings.Contains(err.Error(), "TEST_DURATION") {
+		t.Errorf("got error %v, want the invalid TEST_DURATION returned", err)
+	}
+}
+
+func TestScenarioResultDuration(t *testing.T) {
+	scenario := newTestScenario(t)
+	scenario.startTime = time.Now().Add(-90 * time.Second)
+
+	result := scenario.Result()
+	if result.Duration < 90*time.Second {
+		t.Errorf("got duration %s, want the time since the scenario was created", result.Duration)
+	}
+	if !result.StartTime.Equal(scenario.startTime) {
+		t.Errorf("got start time %s, want %s", result.StartTime, scenario.startTime)
+	}
+}
+
+func TestRemovePrometheusData(t *testing.T) {
+	scenario := newTestScenario(t)
+	if err := os.MkdirAll(filepath.Join(scenario.promDataDir, "wal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	scenario.RemovePrometheusData()
+	if _, err := os.Stat(scenario.promDataDir); !os.IsNotExist(err) {
+		t.Errorf("got %v for %s, want it removed", err, scenario.promDataDir)
+	}
+}
+
+func TestRemovePrometheusDataUserDir(t *testing.T) {
+	scenario := newTestScenario(t)
+	scenario.promDataDir = t.TempDir()
+	scenario.userPromDataDir = true
+	scenario.RemovePrometheusData()
+	if _, err := os.Stat(scenario.promDataDir); err != nil {
+		t.Errorf("got %v, want the directory given by --storage.tsdb.path kept", err)
+	}
+}
+
+func TestStopPrometheusMeasuresTSDB(t *testing.T) {
+	scenario := newTestScenario(t)
+	if err := os.MkdirAll(filepath.Join(scenario.promDataDir, "wal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(scenario.promDataDir, "wal", "00000000"), make([]byte, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scenario.StopPrometheus()
+	result := scenario.Result()
+	if result.TSDB == nil {
+		t.Fatal("got no TSDB footprint in the result of a stopped Prometheus")
+	}
+	if result.TSDB.WALBytes != 1024 {
+		t.Errorf("got WAL of %d bytes, want 1024", result.TSDB.WALBytes)
+	}
+}
+
+// readinessServer answers /-/healthy and /-/ready with 503 until they were requested unhealthy and
+// unready times, and records the order of the requests.
+type readinessServer struct {
+	mutex     sync.Mutex
+	unhealthy int
+	unready   int
+	requests  []string
+}
+
+func (s *readinessServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, r.URL.Path)
+
+	remaining := &s.unready
+	if r.URL.Path == "/-/healthy" {
+		remaining = &s.unhealthy
+	}
+	if *remaining != 0 {
+		*remaining--
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, "OK")
+}
+
+// startReadinessServer starts s and points the scenario to it.
+func startReadinessServer(t *testing.T, scenario *Scenario, s *readinessServer) {
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	scenario.promPort, _ = strconv.Atoi(u.Port())
+}
+
+func TestWaitForPrometheusReady(t *testing.T) {
+	scenario := newTestScenario(t)
+	server := &readinessServer{unhealthy: 2, unready: 1}
+	startReadinessServer(t, scenario, server)
+
+	latency, ok := scenario.waitForPrometheusReady()
+	if !ok || scenario.Failed() {
+		t.Fatalf("not ready: %s", scenario.errorCause)
+	}
+	// 5ms, 10ms and 20ms between the requests.
+	if latency < 35*time.Millisecond {
+		t.Errorf("got latency %s", latency)
+	}
+	want := "/-/healthy,/-/healthy,/-/healthy,/-/ready,/-/ready"
+	if got := strings.Join(server.requests, ","); got != want {
+		t.Errorf("got requests %s, want %s", got, want)
+	}
+}
+
+func TestWaitForPrometheusReadyTimeout(t *testing.T) {
+	scenario := newTestScenario(t)
+	scenario.promReadyTimeout = 100 * time.Millisecond
+	startReadinessServer(t, scenario, &readinessServer{unready: -1})
+
+	if _, ok := scenario.waitForPrometheusReady(); ok {
+		t.Fatal("ready although /-/ready never answered 200")
+	}
+	if !strings.Contains(scenario.errorCause, "did not become ready within 100ms") || !strings.Contains(scenario.errorCause, "503") {
+		t.Errorf("unexpected error %q", scenario.errorCause)
+	}
+}
+
+func TestWaitForPrometheusReadyError(t *testing.T) {
+	scenario := newTestScenario(t)
+	startReadinessServer(t, scenario, &readinessServer{unhealthy: -1})
+
+	time.AfterFunc(50*time.Millisecond, func() { scenario.indicateError(fmt.Errorf("collector exited")) })
+	start := time.Now()
+	if _, ok := scenario.waitForPrometheusReady(); ok {
+		t.Fatal("ready although /-/healthy never answered 200")
+	}
+	if time.Since(start) > 10*time.Second || scenario.errorCause != "collector exited" {
+		t.Errorf("waited %s for error %q", time.Since(start), scenario.errorCause)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// RunSummary is the machine-readable result of one scenario run, written to summary.json.
+type RunSummary struct {
+	Scenario   string        `json:"scenario"`
+	Mode       IngestionMode `json:"mode"`
+	Result     string        `json:"result"`
+	ErrorCause string        `json:"error_cause,omitempty"`
+
+	StartTime           time.Time   `json:"start_time"`
+	DurationSeconds     float64     `json:"duration_seconds"`
+	LoadDurationSeconds float64     `json:"load_duration_seconds"`
+	Load                LoadSummary `json:"load"`
+
+	ItemsSent           uint64  `json:"items_sent"`
+	ItemsReceived       uint64  `json:"items_received"`
+	ItemsDropped        uint64  `json:"items_dropped"`
+	ThroughputPerSecond float64 `json:"throughput_per_second"`
+
+	Collector  ProcessSummary `json:"collector"`
+	Prometheus ProcessSummary `json:"prometheus"`
+	// PrometheusReadySeconds is the time from starting Prometheus until /-/ready answered.
+	PrometheusReadySeconds float64 `json:"prometheus_ready_seconds"`
+	// PrometheusMetrics holds the last value of the scraped Prometheus self-metrics by series.
+	PrometheusMetrics map[string]float64 `json:"prometheus_metrics,omitempty"`
+	// CollectorExporters and CollectorReceivers hold the final point counters of the collector components.
+	CollectorExporters []ExporterStats `json:"collector_exporters,omitempty"`
+	CollectorReceivers []ReceiverStats `json:"collector_receivers,omitempty"`
+	// TSDB is the on-disk footprint of the data stored by Prometheus.
+	TSDB *TSDBFootprint `json:"tsdb,omitempty"`
+
+	DataValidation *DataValidationReport `json:"data_validation,omitempty"`
+	// OutOfOrder compares the late and out-of-order data sent with the samples Prometheus accepted.
+	OutOfOrder *OutOfOrderReport `json:"out_of_order,omitempty"`
+	// Exemplars compares the exemplars sent with those Prometheus stored.
+	Exemplars *ExemplarReport `json:"exemplars,omitempty"`
+	// HistogramQueries holds the results of the queries over the classic and native histograms.
+	HistogramQueries []HistogramQueryResult `json:"histogram_queries,omitempty"`
+	// Restart compares the data sent while Prometheus restarted with the samples it stored.
+	Restart *RestartReport `json:"restart,omitempty"`
+
+	Host HostSummary `json:"host"`
+}
+
+// LoadSummary mirrors testbed.LoadOptions.
+type LoadSummary struct {
+	DataItemsPerSecond int               `json:"data_items_per_second"`
+	ItemsPerBatch      int               `json:"items_per_batch"`
+	Parallel           int               `json:"parallel"`
+	Attributes         map[string]string `json:"attributes,omitempty"`
+}
+
+// ProcessSummary holds the version and the total resource consumption of a process.
+type ProcessSummary struct {
+	ExePath       string  `json:"exe_path"`
+	Version       string  `json:"version"`
+	CPUPercentAvg float64 `json:"cpu_percent_avg"`
+	CPUPercentMax float64 `json:"cpu_percent_max"`
+	RAMMiBAvg     uint32  `json:"ram_mib_avg"`
+	RAMMiBMax     uint32  `json:"ram_mib_max"`
+}
+
+// HostSummary describes the machine the run was executed on.
+type HostSummary struct {
+	Hostname        string `json:"hostname"`
+	OS              string `json:"os"`
+	Platform        string `json:"platform"`
+	PlatformVersion string `json:"platform_version"`
+	KernelVersion   string `json:"kernel_version"`
+	Arch            string `json:"arch"`
+	CPUModel        string `json:"cpu_model"`
+	CPUCores        int    `json:"cpu_cores"`
+	MemoryTotalMiB  uint64 `json:"memory_total_mib"`
+	GoVersion       string `json:"go_version"`
+}
+
+// newRunSummary builds the summary of a run from its result and spec.
+func newRunSummary(result *ScenarioResult, spec ScenarioSpec) *RunSummary {
+	summary := &RunSummary{
+		Scenario:            result.Name,
+		Mode:                result.Mode,
+		Result:              "PASS",
+		ErrorCause:          result.ErrorCause,
+		StartTime:           result.StartTime,
+		DurationSeconds:     result.Duration.Seconds(),
+		LoadDurationSeconds: result.LoadDuration.Seconds(),
+		Load: LoadSummary{
+			DataItemsPerSecond: result.Load.DataItemsPerSecond,
+			ItemsPerBatch:      result.Load.ItemsPerBatch,
+			Parallel:           result.Load.Parallel,
+			Attributes:         result.Load.Attributes,
+		},
+		ItemsSent:              result.ItemsSent,
+		ItemsReceived:          result.ItemsReceived,
+		ItemsDropped:           result.Dropped(),
+		ThroughputPerSecond:    result.Throughput(),
+		Collector:              newProcessSummary(spec.Collector.ExePath, result.Agent),
+		Prometheus:             newProcessSummary(spec.Prometheus.ExePath, result.Prometheus),
+		PrometheusReadySeconds: result.PrometheusReadyLatency.Seconds(),
+		PrometheusMetrics:      result.PrometheusMetrics,
+		CollectorExporters:     result.Exporters,
+		CollectorReceivers:     result.Receivers,
+		TSDB:                   result.TSDB,
+		DataValidation:         result.DataValidation,
+		OutOfOrder:             result.OutOfOrder,
+		Exemplars:              result.Exemplars,
+		HistogramQueries:       result.HistogramQueries,
+		Restart:                result.Restart,
+		Host:                   newHostSummary(),
+	}
+	if result.ErrorCause != "" {
+		summary.Result = "FAIL"
+	}
+	return summary
+}
+
+func newProcessSummary(exePath string, rc *testbed.ResourceConsumption) ProcessSummary {
+	return ProcessSummary{
+		ExePath:       exePath,
+		Version:       exeVersion(exePath),
+		CPUPercentAvg: rc.CPUPercentAvg,
+		CPUPercentMax: rc.CPUPercentMax,
+		RAMMiBAvg:     rc.RAMMiBAvg,
+		RAMMiBMax:     rc.RAMMiBMax,
+	}
+}
+
+// exeVersion returns the first line printed by "<exePath> --version", which both the collector and
+// Prometheus support, or an empty string if the executable cannot be run.
+func exeVersion(exePath string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// #nosec
+	out, err := exec.CommandContext(ctx, expandExeFileName(exePath), "--version").CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	line, _ := bufio.NewReader(bytes.NewReader(out)).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// newHostSummary collects the host information, leaving out what cannot be read.
+func newHostSummary() HostSummary {
+	summary := HostSummary{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+	}
+	if info, err := host.Info(); err == nil {
+		summary.Hostname = info.Hostname
+		summary.Platform = info.Platform
+		summary.PlatformVersion = info.PlatformVersion
+		summary.KernelVersion = info.KernelVersion
+	}
+	if infos, err := cpu.Info(); err == nil && len(infos) > 0 {
+		summary.CPUModel = infos[0].ModelName
+	}
+	if cores, err := cpu.Counts(true); err == nil {
+		summary.CPUCores = cores
+	}
+	if vm, err := mem.VirtualMemory(); err == nil {
+		summary.MemoryTotalMiB = vm.Total / mibibyte
+	}
+	return summary
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	testbed "github.com/open-telemetry/opentelemetry-collector-contrib/testbed/testbed"
+)
+
+func TestNewRunSummary(t *testing.T) {
+	start := time.Date(2023, 9, 20, 10, 0, 0, 0, time.UTC)
+	spec := DefaultScenarioSpec()
+	spec.Collector.ExePath = "/nonexistent/otelcol"
+	result := &ScenarioResult{
+		Name:          "remote-write",
+		Mode:          ModeRemoteWrite,
+		Load:          testbed.LoadOptions{DataItemsPerSecond: 1000, ItemsPerBatch: 10, Parallel: 1},
+		StartTime:     start,
+		Duration:      70 * time.Second,
+		LoadDuration:  60 * time.Second,
+		ItemsSent:     60000,
+		ItemsReceived: 59000,
+		Agent:         &testbed.ResourceConsumption{CPUPercentAvg: 12, RAMMiBMax: 150},
+		Prometheus:    &testbed.ResourceConsumption{CPUPercentMax: 30},
+		ErrorCause:    "Received and sent counters do not match",
+	}
+
+	summary := newRunSummary(result, spec)
+	if !summary.StartTime.Equal(start) || summary.DurationSeconds != 70 || summary.LoadDurationSeconds != 60 {
+		t.Errorf("got start %s, duration %gs, load duration %gs", summary.StartTime, summary.DurationSeconds, summary.LoadDurationSeconds)
+	}
+	if summary.Result != "FAIL" || summary.ItemsDropped != 1000 || summary.ThroughputPerSecond != 59000.0/60 || summary.Load.ItemsPerBatch != 10 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if summary.Collector.CPUPercentAvg != 12 || summary.Collector.RAMMiBMax != 150 || summary.Collector.Version != "" || summary.Prometheus.CPUPercentMax != 30 {
+		t.Errorf("unexpected process summaries %+v %+v", summary.Collector, summary.Prometheus)
+	}
+
+	out, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"start_time":"2023-09-20T10:00:00Z","duration_seconds":70,`) {
+		t.Errorf("unexpected JSON %s", out)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// The functions below predict the Prometheus series an OTLP metric is stored as. They follow the
+// normalization shared by the prometheusremotewrite exporter and the Prometheus OTLP receiver with
+// metric suffixes enabled, which is the default of both.
+
+// unitMap maps OTLP units to the Prometheus unit suffix.
+var unitMap = map[string]string{
+	// Time
+	"d":   "days",
+	"h":   "hours",
+	"min": "minutes",
+	"s":   "seconds",
+	"ms":  "milliseconds",
+	"us":  "microseconds",
+	"ns":  "nanoseconds",
+
+	// Bytes
+	"By":   "bytes",
+	"KiBy": "kibibytes",
+	"MiBy": "mebibytes",
+	"GiBy": "gibibytes",
+	"TiBy": "tibibytes",
+	"KBy":  "kilobytes",
+	"MBy":  "megabytes",
+	"GBy":  "gigabytes",
+	"TBy":  "terabytes",
+
+	// SI
+	"m":   "meters",
+	"V":   "volts",
+	"A":   "amperes",
+	"J":   "joules",
+	"W":   "watts",
+	"g":   "grams",
+	"Cel": "celsius",
+	"Hz":  "hertz",
+	"1":   "",
+	"%":   "percent",
+}
+
+// perUnitMap maps the OTLP unit after a "/" to the Prometheus "per_" suffix.
+var perUnitMap = map[string]string{
+	"s":  "second",
+	"m":  "minute",
+	"h":  "hour",
+	"d":  "day",
+	"w":  "week",
+	"mo": "month",
+	"y":  "year",
+}
+
+func isNotAlnum(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// cleanUpString joins the alphanumeric runs of s with underscores.
+func cleanUpString(s string) string {
+	return strings.Join(strings.FieldsFunc(s, isNotAlnum), "_")
+}
+
+func lookupUnit(units map[string]string, unit string) string {
+	if promUnit, ok := units[unit]; ok {
+		return promUnit
+	}
+	return unit
+}
+
+func removeToken(tokens []string, token string) []string {
+	kept := tokens[:0]
+	for _, t := range tokens {
+		if t != token {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func containsToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// promMetricName returns the Prometheus metric name of an OTLP metric, without the _bucket, _sum and
+// _count suffixes of histograms and summaries.
+func promMetricName(metric pmetric.Metric) string {
+	tokens := strings.FieldsFunc(metric.Name(), isNotAlnum)
+
+	unitTokens := strings.SplitN(metric.Unit(), "/", 2)
+	if mainUnit := strings.TrimSpace(unitTokens[0]); mainUnit != "" && !strings.ContainsAny(mainUnit, "{}") {
+		if promUnit := cleanUpString(lookupUnit(unitMap, mainUnit)); promUnit != "" && !containsToken(tokens, promUnit) {
+			tokens = append(tokens, promUnit)
+		}
+	}
+	if len(unitTokens) > 1 {
+		if perUnit := strings.TrimSpace(unitTokens[1]); perUnit != "" && !strings.ContainsAny(perUnit, "{}") {
+			if promUnit := cleanUpString(lookupUnit(perUnitMap, perUnit)); promUnit != "" && !containsToken(tokens, promUnit) {
+				tokens = append(tokens, "per", promUnit)
+			}
+		}
+	}
+
+	// Monotonic sums are counters.
+	if metric.Type() == pmetric.MetricTypeSum && metric.Sum().IsMonotonic() {
+		tokens = append(removeToken(tokens, "total"), "total")
+	}
+
+	// Gauges with unit "1" are ratios.
+	if metric.Unit() == "1" && metric.Type() == pmetric.MetricTypeGauge {
+		tokens = append(removeToken(tokens, "ratio"), "ratio")
+	}
+
+	name := strings.Join(tokens, "_")
+	if name != "" && unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
+
+// promLabelName returns the Prometheus label name of an OTLP attribute key.
+func promLabelName(key string) string {
+	if key == "" {
+		return key
+	}
+
+	label := strings.Map(func(r rune) rune {
+		if isNotAlnum(r) {
+			return '_'
+		}
+		return r
+	}, key)
+
+	if unicode.IsDigit(rune(label[0])) {
+		return "key_" + label
+	}
+	if strings.HasPrefix(label, "_") && !strings.HasPrefix(label, "__") {
+		return "key" + label
+	}
+	return label
+}
+
+// formatBound formats a histogram bucket bound or summary quantile the way the "le" and "quantile"
+// labels are written.
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
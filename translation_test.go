@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestPromMetricName(t *testing.T) {
+	newMetric := func(name, unit string, setType func(pmetric.Metric)) pmetric.Metric {
+		metric := pmetric.NewMetric()
+		metric.SetName(name)
+		metric.SetUnit(unit)
+		setType(metric)
+		return metric
+	}
+	gauge := func(m pmetric.Metric) { m.SetEmptyGauge() }
+	counter := func(m pmetric.Metric) { m.SetEmptySum().SetIsMonotonic(true) }
+	upDownCounter := func(m pmetric.Metric) { m.SetEmptySum() }
+	histogram := func(m pmetric.Metric) { m.SetEmptyHistogram() }
+
+	for _, tt := range []struct {
+		metric pmetric.Metric
+		want   string
+	}{
+		{newMetric("http.server.duration", "ms", histogram), "http_server_duration_milliseconds"},
+		{newMetric("system.memory.usage", "By", upDownCounter), "system_memory_usage_bytes"},
+		{newMetric("requests", "1", counter), "requests_total"},
+		{newMetric("requests.total", "{requests}", counter), "requests_total"},
+		{newMetric("cpu.utilization", "1", gauge), "cpu_utilization_ratio"},
+		{newMetric("network.io", "By/s", gauge), "network_io_bytes_per_second"},
+		{newMetric("duration_seconds", "s", gauge), "duration_seconds"},
+		{newMetric("2xx.responses", "", counter), "_2xx_responses_total"},
+		{newMetric("", "1", gauge), "ratio"},
+		{newMetric("", "", gauge), ""},
+	} {
+		if got := promMetricName(tt.metric); got != tt.want {
+			t.Errorf("promMetricName(%q, %q) = %q, want %q", tt.metric.Name(), tt.metric.Unit(), got, tt.want)
+		}
+	}
+}
+
+func TestPromLabelName(t *testing.T) {
+	for key, want := range map[string]string{
+		"service.name": "service_name",
+		"http-method":  "http_method",
+		"0_index":      "key_0_index",
+		"_private":     "key_private",
+		"__reserved":   "__reserved",
+	} {
+		if got := promLabelName(key); got != want {
+			t.Errorf("promLabelName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
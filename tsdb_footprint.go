@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// TSDBFootprint is the on-disk cost of the data Prometheus stored during a run, read from its TSDB
+// directory after it stopped. Native OTLP ingestion and remote write produce different label sets,
+// e.g. target_info and job/instance, so the cost per sample and series differs between the modes.
+type TSDBFootprint struct {
+	Dir string `json:"dir"`
+	// WALBytes is the size of the write-ahead log including its checkpoints, WBLBytes the size of the
+	// write-behind log of out-of-order samples.
+	WALBytes int64 `json:"wal_bytes"`
+	WBLBytes int64 `json:"wbl_bytes"`
+	// ChunksHeadBytes is the size of the memory-mapped head chunks.
+	ChunksHeadBytes int64 `json:"chunks_head_bytes"`
+	// Blocks are the persisted blocks, with their size and the stats of their meta.json.
+	Blocks       int    `json:"blocks"`
+	BlockBytes   int64  `json:"block_bytes"`
+	BlockSeries  uint64 `json:"block_series"`
+	BlockSamples uint64 `json:"block_samples"`
+	// TotalBytes is the size of the whole directory.
+	TotalBytes int64 `json:"total_bytes"`
+	// HeadSeries and SamplesAppended are the last scraped prometheus_tsdb_head_series and
+	// prometheus_tsdb_head_samples_appended_total summed over restarts of Prometheus, the samples
+	// appended since start include those compacted into blocks since.
+	HeadSeries      uint64 `json:"head_series"`
+	SamplesAppended uint64 `json:"samples_appended"`
+	// BytesPerSample and BytesPerSeries divide TotalBytes by the samples appended and the head series,
+	// falling back to the block stats when Prometheus was not scraped.
+	BytesPerSample float64 `json:"bytes_per_sample"`
+	BytesPerSeries float64 `json:"bytes_per_series"`
+}
+
+// blockMeta is the part of the meta.json of a block holding its stats.
+type blockMeta struct {
+	Stats struct {
+		NumSamples uint64 `json:"numSamples"`
+		NumSeries  uint64 `json:"numSeries"`
+	} `json:"stats"`
+}
+
+// newTSDBFootprint walks the TSDB directory dir and combines its sizes with the last scraped
+// Prometheus self-metrics in samples.
+func newTSDBFootprint(dir string, samples []ScrapedSample) (*TSDBFootprint, error) {
+	footprint := &TSDBFootprint{Dir: dir}
+
+	var err error
+	if footprint.TotalBytes, err = dirSize(dir); err != nil {
+		return nil, err
+	}
+	if footprint.WALBytes, err = dirSize(filepath.Join(dir, "wal")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if footprint.WBLBytes, err = dirSize(filepath.Join(dir, "wbl")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if footprint.ChunksHeadBytes, err = dirSize(filepath.Join(dir, "chunks_head")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		blockDir := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(blockDir, "meta.json"))
+		if err != nil {
+			// Not a block, or a block still being written.
+			continue
+		}
+		var meta blockMeta
+		if err = json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("cannot read meta.json of block %s: %w", entry.Name(), err)
+		}
+		size, err := dirSize(blockDir)
+		if err != nil {
+			return nil, err
+		}
+		footprint.Blocks++
+		footprint.BlockBytes += size
+		footprint.BlockSeries += meta.Stats.NumSeries
+		footprint.BlockSamples += meta.Stats.NumSamples
+	}
+
+	for _, sample := range samples {
+		switch sample.Name {
+		case "prometheus_tsdb_head_series":
+			footprint.HeadSeries += uint64(sample.Value)
+		case "prometheus_tsdb_head_samples_appended_total":
+			// Split by type into float and histogram samples by recent Prometheus versions.
+			footprint.SamplesAppended += uint64(sample.Value)
+		}
+	}
+
+	samplesStored, seriesStored := footprint.SamplesAppended, footprint.HeadSeries
+	if samplesStored == 0 {
+		samplesStored = footprint.BlockSamples
+	}
+	if seriesStored == 0 {
+		seriesStored = footprint.BlockSeries
+	}
+	if samplesStored > 0 {
+		footprint.BytesPerSample = float64(footprint.TotalBytes) / float64(samplesStored)
+	}
+	if seriesStored > 0 {
+		footprint.BytesPerSeries = float64(footprint.TotalBytes) / float64(seriesStored)
+	}
+	return footprint, nil
+}
+
+// dirSize returns the total size of the regular files below dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func (f *TSDBFootprint) String() string {
+	return fmt.Sprintf("total:%.1f MiB wal:%.1f MiB wbl:%.1f MiB chunks_head:%.1f MiB blocks:%d (%.1f MiB) series:%d samples:%d bytes/sample:%.2f bytes/series:%.0f",
+		float64(f.TotalBytes)/mibibyte, float64(f.WALBytes)/mibibyte, float64(f.WBLBytes)/mibibyte,
+		float64(f.ChunksHeadBytes)/mibibyte, f.Blocks, float64(f.BlockBytes)/mibibyte,
+		f.HeadSeries, f.SamplesAppended, f.BytesPerSample, f.BytesPerSeries)
+}
+
+// analyzeTSDB runs "promtool tsdb analyze" on the TSDB directory dir and writes its output to fileName.
+// promtool only analyzes persisted blocks, so runs shorter than the head compaction have nothing to analyze.
+func analyzeTSDB(dir string, prometheusExePath string, fileName string) error {
+	promtool := promtoolPath(prometheusExePath)
+	if promtool == "" {
+		return fmt.Errorf("promtool not found")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	// #nosec
+	out, err := exec.CommandContext(ctx, promtool, "tsdb", "analyze", dir).CombinedOutput()
+	if writeErr := os.WriteFile(fileName, out, 0644); writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s tsdb analyze: %w", promtool, err)
+	}
+	log.Printf("TSDB analysis written to %s", fileName)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTSDBFootprint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int) {
+		fileName := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("wal/00000000", 1000)
+	write("wal/checkpoint.00000001/00000000", 500)
+	write("chunks_head/000001", 300)
+	write("01HBLOCK/chunks/000001", 2000)
+	write("01HBLOCK/index", 150)
+	write("lock", 0)
+	meta := `{"ulid":"01HBLOCK","stats":{"numSamples":400,"numSeries":10,"numChunks":20}}`
+	if err := os.WriteFile(filepath.Join(dir, "01HBLOCK", "meta.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	footprint, err := newTSDBFootprint(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := int64(1000 + 500 + 300 + 2000 + 150 + len(meta))
+	if footprint.TotalBytes != total || footprint.WALBytes != 1500 || footprint.ChunksHeadBytes != 300 || footprint.WBLBytes != 0 {
+		t.Errorf("got sizes %+v", footprint)
+	}
+	if footprint.Blocks != 1 || footprint.BlockBytes != int64(2150+len(meta)) || footprint.BlockSeries != 10 || footprint.BlockSamples != 400 {
+		t.Errorf("got blocks %+v", footprint)
+	}
+	if footprint.BytesPerSample != float64(total)/400 {
+		t.Errorf("got %f bytes per sample from the block stats", footprint.BytesPerSample)
+	}
+
+	// The scraped head metrics take precedence over the block stats.
+	footprint, err = newTSDBFootprint(dir, []ScrapedSample{
+		{Name: "prometheus_tsdb_head_series", Value: 50},
+		{Name: "prometheus_tsdb_head_samples_appended_total", Labels: map[string]string{"type": "float"}, Value: 900},
+		{Name: "prometheus_tsdb_head_samples_appended_total", Labels: map[string]string{"type": "histogram"}, Value: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if footprint.SamplesAppended != 1000 || footprint.BytesPerSample != float64(total)/1000 || footprint.BytesPerSeries != float64(total)/50 {
+		t.Errorf("got %+v", footprint)
+	}
+
+	if _, err = newTSDBFootprint(filepath.Join(dir, "missing"), nil); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}